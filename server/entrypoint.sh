@@ -10,11 +10,25 @@ BACKUP_PATH="/bak"
 ZTNCUI_PATH="${APP_PATH}/ztncui"
 ZTNCUI_SRC_PATH="${ZTNCUI_PATH}/src"
 
+# 启动文件服务器，存在 Go 版本 fileserver 时优先使用，支持 key 参数和 hmac 签名认证
+function start_file_server() {
+    if [ -x "${APP_PATH}/fileserver" ]; then
+        hmac_args=""
+        if [ -f "${CONFIG_PATH}/file_server.hmac" ]; then
+            hmac_args="-hmacKeysFile ${CONFIG_PATH}/file_server.hmac"
+        fi
+        nohup ${APP_PATH}/fileserver -listen ":${FILE_SERVER_PORT}" -dir ${APP_PATH}/dist \
+            -keyFile ${CONFIG_PATH}/file_server.key ${hmac_args} &> ${APP_PATH}/server.log &
+    else
+        nohup node ${APP_PATH}/http_server.js &> ${APP_PATH}/server.log &
+    fi
+}
+
 # 启动 ZeroTier 和 ztncui
 function start() {
     echo "Start ztncui and zerotier"
     cd $ZEROTIER_PATH && ./zerotier-one -p$(cat ${CONFIG_PATH}/zerotier-one.port) -d || exit 1
-    nohup node ${APP_PATH}/http_server.js &> ${APP_PATH}/server.log & 
+    start_file_server
     # 新增变量和语句,填写域名则根据域名IP自动更新planet和moon
     if [ -n "${DOMAIN}" ]; then
         echo "启动域名解析更新功能"
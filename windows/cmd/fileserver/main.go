@@ -0,0 +1,173 @@
+// fileserver 为客户端提供 planet、ips 等文件下载，支持 URL 中的 key 参数认证和 HMAC 签名认证。
+// 用于替代镜像中的 http_server.js，签名认证使用 pkg/auth 包的 Verifier 校验时间偏差并防止 nonce 重放。
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	auth "github.com/onlypeng/zerotier-extend/windows/pkg/auth"
+)
+
+func main() {
+	listen := flag.String("listen", ":3000", "监听地址")
+	dir := flag.String("dir", "/app/dist", "提供下载的文件目录")
+	keyFile := flag.String("keyFile", "", "key 参数认证使用的密钥文件，为空时不允许 key 认证")
+	hmacKeysFile := flag.String("hmacKeysFile", "", "hmac 认证的密钥文件，每行 keyId=secret，为空时不允许 hmac 认证")
+	maxSkew := flag.Int("maxSkew", int(auth.DefaultMaxSkew/time.Second), "hmac 认证允许的时间偏差（秒）")
+	tlsCert := flag.String("tlsCert", "", "TLS 证书文件，为空时使用 HTTP")
+	tlsKey := flag.String("tlsKey", "", "TLS 私钥文件")
+	flag.Parse()
+
+	var key string
+	if *keyFile != "" {
+		data, err := os.ReadFile(*keyFile)
+		if err != nil {
+			log.Fatalf("读取密钥文件失败: %v", err)
+		}
+		if key = strings.TrimSpace(string(data)); key == "" {
+			log.Fatalf("密钥文件 %s 为空", *keyFile)
+		}
+	}
+	var verifier *auth.Verifier
+	if *hmacKeysFile != "" {
+		keys, err := loadHMACKeys(*hmacKeysFile)
+		if err != nil {
+			log.Fatalf("读取hmac密钥失败: %v", err)
+		}
+		verifier = newVerifier(keys, time.Duration(*maxSkew)*time.Second)
+		log.Printf("已启用hmac认证，共%d个密钥", len(keys))
+	}
+	if key == "" && verifier == nil {
+		log.Fatalf("需要配置 -keyFile 或 -hmacKeysFile")
+	}
+
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           newHandler(*dir, key, verifier),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("文件服务器监听 %s，目录 %s", *listen, *dir)
+	var err error
+	if *tlsCert != "" {
+		err = srv.ListenAndServeTLS(*tlsCert, *tlsKey)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	log.Fatalf("文件服务器退出: %v", err)
+}
+
+// loadHMACKeys 读取 hmac 密钥文件，每行 keyId=secret，忽略空行和 # 开头的注释
+func loadHMACKeys(path string) (map[string][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	keys := make(map[string][]byte)
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, secret, ok := strings.Cut(line, "=")
+		id, secret = strings.TrimSpace(id), strings.TrimSpace(secret)
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("第%d行格式错误，应为 keyId=secret", n)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, fmt.Errorf("第%d行 keyId %s 重复", n, id)
+		}
+		keys[id] = []byte(secret)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("没有配置任何密钥")
+	}
+	return keys, nil
+}
+
+// newVerifier 创建使用固定密钥表的签名校验器
+func newVerifier(keys map[string][]byte, maxSkew time.Duration) *auth.Verifier {
+	return &auth.Verifier{
+		Lookup: func(keyID string) ([]byte, bool) {
+			secret, ok := keys[keyID]
+			return secret, ok
+		},
+		MaxSkew: maxSkew,
+	}
+}
+
+// newHandler 返回文件下载处理器：带 Authorization 头的请求按 hmac 校验，否则校验 key 参数，
+// 只提供 dir 下的普通文件，不列出目录
+func newHandler(dir, key string, verifier *auth.Verifier) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := authorize(r, key, verifier); err != nil {
+			log.Printf("拒绝 %s 的请求 %s: %v", r.RemoteAddr, r.URL.Path, err)
+			if verifier != nil {
+				w.Header().Set("WWW-Authenticate", auth.Scheme)
+			}
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		name := path.Clean("/" + r.URL.Path)
+		if name == "/" || strings.HasPrefix(path.Base(name), ".") {
+			http.NotFound(w, r)
+			return
+		}
+		file, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			http.NotFound(w, r)
+			return
+		}
+		// ServeContent 处理 Last-Modified 和 If-Modified-Since，客户端可用条件请求避免重复下载
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeContent(w, r, name, info.ModTime(), file)
+	})
+}
+
+// authorize 校验请求认证，hmac 请求不再接受 key 参数
+func authorize(r *http.Request, key string, verifier *auth.Verifier) error {
+	if r.Header.Get("Authorization") != "" {
+		if verifier == nil {
+			return errors.New("未启用hmac认证")
+		}
+		_, err := verifier.Verify(r)
+		return err
+	}
+	if key == "" {
+		return auth.ErrMissing
+	}
+	got := r.URL.Query().Get("key")
+	if got == "" || subtle.ConstantTimeCompare([]byte(got), []byte(key)) != 1 {
+		return errors.New("key错误")
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	auth "github.com/onlypeng/zerotier-extend/windows/pkg/auth"
+)
+
+func testServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "planet"), []byte("PLANET"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ips"), []byte("203.0.113.10,"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	verifier := newVerifier(map[string][]byte{"node1": []byte("secret")}, time.Minute)
+	srv := httptest.NewServer(newHandler(dir, "k123", verifier))
+	t.Cleanup(srv.Close)
+	return srv, dir
+}
+
+func fetch(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestFileServerKeyAuth(t *testing.T) {
+	srv, _ := testServer(t)
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/planet?key=k123", http.StatusOK, "PLANET"},
+		{"/ips?key=k123", http.StatusOK, "203.0.113.10,"},
+		{"/planet", http.StatusUnauthorized, ""},
+		{"/planet?key=wrong", http.StatusUnauthorized, ""},
+		{"/missing?key=k123", http.StatusNotFound, ""},
+		{"/sub?key=k123", http.StatusNotFound, ""},
+		{"/?key=k123", http.StatusNotFound, ""},
+		{"/../planet?key=k123", http.StatusOK, "PLANET"},
+	}
+	for _, tt := range tests {
+		code, body := fetch(t, http.DefaultClient, srv.URL+tt.path)
+		if code != tt.code || (tt.body != "" && body != tt.body) {
+			t.Errorf("%s: 状态码 %d 内容 %q，期望 %d %q", tt.path, code, body, tt.code, tt.body)
+		}
+	}
+}
+
+func TestFileServerHMACAuth(t *testing.T) {
+	srv, _ := testServer(t)
+	client := &http.Client{Transport: &auth.Transport{
+		Base:   http.DefaultTransport,
+		Signer: &auth.Signer{KeyID: "node1", Secret: []byte("secret")},
+	}}
+	if code, body := fetch(t, client, srv.URL+"/planet"); code != http.StatusOK || body != "PLANET" {
+		t.Errorf("签名请求状态码 %d 内容 %q", code, body)
+	}
+
+	wrong := &http.Client{Transport: &auth.Transport{
+		Base:   http.DefaultTransport,
+		Signer: &auth.Signer{KeyID: "node1", Secret: []byte("other")},
+	}}
+	// 签名错误时即使带有正确的 key 参数也拒绝
+	if code, _ := fetch(t, wrong, srv.URL+"/planet?key=k123"); code != http.StatusUnauthorized {
+		t.Errorf("错误签名的状态码为 %d", code)
+	}
+
+	// 同一个签名请求不能重放
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/planet", nil)
+	if err := (&auth.Signer{KeyID: "node1", Secret: []byte("secret")}).Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("第%d次发送状态码为 %d，期望 %d", i+1, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestLoadHMACKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(path, []byte("# 注释\nnode1 = s1\n\nnode2=s2=x\n"), 0600)
+	keys, err := loadHMACKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(keys["node1"]) != "s1" || string(keys["node2"]) != "s2=x" || len(keys) != 2 {
+		t.Errorf("密钥为 %q", keys)
+	}
+
+	for _, content := range []string{"", "node1\n", "node1=a\nnode1=b\n", "=s\n"} {
+		os.WriteFile(path, []byte(content), 0600)
+		if _, err := loadHMACKeys(path); err == nil {
+			t.Errorf("%q: 未返回错误", content)
+		}
+	}
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
 	logger "github.com/onlypeng/zerotier-extend/windows/internal/logger"
+	planet "github.com/onlypeng/zerotier-extend/windows/internal/planet"
 	myservice "github.com/onlypeng/zerotier-extend/windows/internal/service"
+	myutiles "github.com/onlypeng/zerotier-extend/windows/internal/utiles"
 
 	"github.com/kardianos/service"
 )
@@ -55,7 +59,7 @@ func main() {
 
 	// 有命令行参数，处理服务命令
 	if len(os.Args) > 1 {
-		handleCommand(os.Args[1], svcInstance)
+		handleCommand(cfg, os.Args[1:], svcInstance)
 		return
 	}
 
@@ -67,7 +71,51 @@ func main() {
 	log.Println("服务已停止")
 }
 
-func handleCommand(cmd string, svc service.Service) {
+func handleCommand(cfg *config.Config, args []string, svc service.Service) {
+	cmd := args[0]
+
+	// 不依赖扩展服务状态的命令
+	switch cmd {
+	case "history":
+		printPlanetHistory(cfg)
+		return
+	case "planet":
+		path := cfg.ZeroTierConfig.PlanetPath
+		if len(args) > 1 {
+			path = args[1]
+		}
+		world, err := planet.ReadFile(path)
+		if err != nil {
+			log.Fatalf("解析planet文件失败: %v", err)
+		}
+		fmt.Print(world)
+		return
+	case "rollback":
+		id := ""
+		if len(args) > 1 {
+			id = args[1]
+		}
+		log.Println("开始回滚planet文件...")
+		if err := myservice.RollbackPlanet(cfg, id); err != nil {
+			log.Fatalf("回滚planet文件失败: %v", err)
+		}
+		log.Println("回滚planet文件成功")
+		return
+	case "pin":
+		target := cfg.ServerConfig.PlanetURL
+		if len(args) > 1 {
+			target = args[1]
+		}
+		printCertPins(cfg, target)
+		return
+	case "reset":
+		if err := myservice.ResetCircuit(cfg); err != nil {
+			log.Fatalf("重置重启熔断失败: %v", err)
+		}
+		log.Println("已重置重启熔断，恢复自动重启")
+		return
+	}
+
 	status, err := svc.Status()
 	if err != nil && err != service.ErrNotInstalled {
 		log.Fatalf("获取服务状态失败: %v", err)
@@ -133,8 +181,100 @@ func handleCommand(cmd string, svc service.Service) {
 		log.Println("服务重启成功")
 	case "status":
 		log.Println("服务状态:", status)
+		printAgentState(cfg)
 	default:
 		log.Printf("未知命令: %s", cmd)
-		fmt.Println("可用命令: install, uninstall, start, stop, restart,status")
+		fmt.Println("可用命令: install, uninstall, start, stop, restart, status, history, rollback [id], planet [file], pin [url], reset")
+	}
+}
+
+func printAgentState(cfg *config.Config) {
+	state, err := myutiles.LoadState(cfg.AppConfig.StateFilePath)
+	if err != nil {
+		log.Printf("读取运行状态失败: %v", err)
+		return
+	}
+	if !state.LastCheck.IsZero() {
+		fmt.Println("最近检测时间:", state.LastCheck.Format("2006-01-02 15:04:05"))
+	}
+	if p := state.Pending; p != nil {
+		fmt.Printf("等待稳定的候选IP: %s（首次发现 %s，已连续 %d 次）\n", p.IPs, p.FirstSeen.Format("2006-01-02 15:04:05"), p.Count)
+	}
+	if len(state.Restarts) > 0 {
+		fmt.Printf("近期重启次数: %d（最近 %s）\n", len(state.Restarts), state.Restarts[len(state.Restarts)-1].Format("2006-01-02 15:04:05"))
+	}
+	if circuit := myservice.CircuitStatus(state); circuit != "" {
+		fmt.Println("告警: 重启已熔断:", circuit)
+	}
+	if schedule, err := myutiles.NewMaintenanceSchedule(cfg.AppConfig.Maintenance); err != nil {
+		log.Printf("解析维护窗口失败: %v", err)
+	} else if schedule.Enabled() {
+		allowed := "不允许重启"
+		if schedule.Allowed(time.Now()) {
+			allowed = "允许重启"
+		}
+		fmt.Printf("维护窗口: %s，当前%s\n", schedule, allowed)
+	}
+	printServerCache(cfg)
+}
+
+// printServerCache 输出缓存的服务器最近一次响应，服务器不可用时也能查看
+func printServerCache(cfg *config.Config) {
+	cache := myutiles.NewHTTPCache(cfg.AppConfig.HTTPCachePath)
+	if entry, err := cache.Get(cfg.ServerConfig.IPsURL); err != nil {
+		log.Printf("%v", err)
+	} else if entry != nil {
+		fmt.Printf("服务器IP(缓存): %s（获取于 %s，最近确认 %s）\n", strings.TrimSpace(string(entry.Body)),
+			entry.FetchedAt.Format("2006-01-02 15:04:05"), entry.CheckedAt.Format("2006-01-02 15:04:05"))
+	}
+	if entry, err := cache.Get(cfg.ServerConfig.PlanetURL); err != nil {
+		log.Printf("%v", err)
+	} else if entry != nil {
+		summary := fmt.Sprintf("%d字节", len(entry.Body))
+		if world, err := planet.Parse(entry.Body); err == nil {
+			summary = fmt.Sprintf("时间戳 %d，根节点IP %v", world.Timestamp, world.IPs())
+		}
+		fmt.Printf("服务器planet(缓存): %s（获取于 %s，最近确认 %s）\n", summary,
+			entry.FetchedAt.Format("2006-01-02 15:04:05"), entry.CheckedAt.Format("2006-01-02 15:04:05"))
+	}
+}
+
+func printCertPins(cfg *config.Config, target string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	certs, err := myutiles.FetchPeerCertificates(ctx, target, 10*time.Second)
+	if err != nil {
+		log.Fatalf("获取服务器证书失败: %v", err)
+	}
+	configured := make(map[string]bool)
+	for _, pin := range cfg.ServerConfig.CertPins {
+		configured[strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")] = true
+	}
+	// 不输出查询参数中的 key
+	fmt.Printf("%s 的证书链:\n", strings.SplitN(target, "?", 2)[0])
+	for i, cert := range certs {
+		pin := myutiles.SPKIPin(cert)
+		note := ""
+		if i == 0 {
+			note = "  <- 服务器证书，将此值加入 server.certPins"
+		}
+		if configured[strings.TrimPrefix(pin, "sha256/")] {
+			note += "（已配置）"
+		}
+		fmt.Printf("[%d] %s，有效期至 %s\n    %s%s\n", i, cert.Subject, cert.NotAfter.Format("2006-01-02"), pin, note)
+	}
+}
+
+func printPlanetHistory(cfg *config.Config) {
+	entries, err := myservice.ListPlanetHistory(cfg)
+	if err != nil {
+		log.Fatalf("读取planet历史版本失败: %v", err)
+	}
+	if len(entries) == 0 {
+		fmt.Println("暂无planet历史版本")
+		return
+	}
+	for _, e := range entries {
+		fmt.Printf("%s  %s  %s  IP: %s\n", e.ID, e.Time.Format("2006-01-02 15:04:05"), e.SHA256[:16], e.IPs)
 	}
 }
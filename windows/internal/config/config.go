@@ -17,6 +17,7 @@ type Config struct {
 	ServerConfig   ServerConfig   `yaml:"server"`
 	ZeroTierConfig ZeroTierConfig `yaml:"zerotier"`
 	ServiceConfig  ServiceConfig  `yaml:"service"`
+	HTTPConfig     HTTPConfig     `yaml:"http"`
 }
 
 // AppConfig 应用程序相关配置
@@ -26,6 +27,86 @@ type AppConfig struct {
 	IPFilePath    string `yaml:"ipFilePath"`
 	ServerIPsPath string `yaml:"serverIPsPath"`
 	CheckInterval int    `yaml:"checkInterval"`
+	// StopTimeout 停止服务时等待正在进行的检测结束或回滚的秒数，小于等于 0 时按服务控制器的重启超时计算
+	StopTimeout int `yaml:"stopTimeout"`
+	// HTTPCachePath 服务器 ips/planet 响应缓存目录，用于条件请求及服务器不可用时查看
+	HTTPCachePath string `yaml:"httpCachePath"`
+	// planet 历史版本目录及保留策略，数量或天数小于等于 0 表示不限制
+	PlanetHistoryPath     string `yaml:"planetHistoryPath"`
+	PlanetHistoryMaxCount int    `yaml:"planetHistoryMaxCount"`
+	PlanetHistoryMaxDays  int    `yaml:"planetHistoryMaxDays"`
+	// 新IP需连续出现 StableChecks 次且持续 StableDuration 秒后才开始更新，避免DNS来回切换导致反复重启
+	StableChecks   int            `yaml:"stableChecks"`
+	StableDuration int            `yaml:"stableDuration"`
+	StateFilePath  string         `yaml:"stateFilePath"`
+	Schedule       ScheduleConfig `yaml:"schedule"`
+	// 维护窗口，窗口外仍检测IP变化，但推迟替换planet和重启服务
+	Maintenance MaintenanceConfig `yaml:"maintenance"`
+	// 重启次数限制，超限后熔断停止重启
+	RestartLimit RestartLimitConfig `yaml:"restartLimit"`
+}
+
+// RestartLimitConfig 重启频率限制，MaxRestarts 为 0 时不限制
+type RestartLimitConfig struct {
+	// Period 秒内最多重启 MaxRestarts 次
+	MaxRestarts int `yaml:"maxRestarts"`
+	Period      int `yaml:"period"`
+	// Cooldown 熔断后经过多少秒自动恢复，0 表示只能通过 reset 命令恢复
+	Cooldown int `yaml:"cooldown"`
+}
+
+// MaintenanceConfig 维护窗口配置，未配置窗口时任何时间都允许重启
+type MaintenanceConfig struct {
+	Windows []MaintenanceWindow `yaml:"windows"`
+	// Timezone 窗口使用的时区，如 Asia/Shanghai，为空时使用系统时区
+	Timezone string `yaml:"timezone"`
+	// Urgent 节点与全部根服务器失联时忽略维护窗口立即更新
+	Urgent bool `yaml:"urgent"`
+}
+
+// MaintenanceWindow 单个维护窗口，cron 与 days/start/end 二选一
+type MaintenanceWindow struct {
+	// Cron 5字段cron表达式（分 时 日 月 星期），表示窗口开始时间，持续 Duration 分钟
+	Cron     string `yaml:"cron"`
+	Duration int    `yaml:"duration"`
+	// Days 星期范围，如 Mon-Fri、Sat,Sun，为空表示每天；Start/End 为 HH:MM，End 不大于 Start 时跨越午夜
+	Days  string `yaml:"days"`
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+// ScheduleConfig 检测调度配置，时间单位均为秒
+type ScheduleConfig struct {
+	// Mode fixed 按 checkInterval 固定间隔检测；adaptive 根据检测结果和DNS记录TTL调整间隔
+	Mode string `yaml:"mode"`
+	// UseTTL 使用DNS记录TTL作为常规检测间隔
+	UseTTL bool `yaml:"useTTL"`
+	// 检测到变更或失败后，在 FastPeriod 内使用 FastInterval 间隔检测
+	FastInterval int `yaml:"fastInterval"`
+	FastPeriod   int `yaml:"fastPeriod"`
+	// 连续稳定每满 BackoffAfter，检测间隔翻倍，最长不超过 MaxInterval
+	BackoffAfter int `yaml:"backoffAfter"`
+	MinInterval  int `yaml:"minInterval"`
+	MaxInterval  int `yaml:"maxInterval"`
+	// Jitter 随机抖动百分比（0-100），避免大量客户端同时请求服务器
+	Jitter int `yaml:"jitter"`
+}
+
+// HTTPConfig 请求服务器 ips/planet 地址使用的 HTTP 客户端配置，时间单位为秒
+type HTTPConfig struct {
+	// ConnectTimeout 建立连接（含TLS握手）超时；ReadTimeout 等待响应及读取数据的间隔超时；Timeout 单次请求总超时
+	ConnectTimeout int `yaml:"connectTimeout"`
+	ReadTimeout    int `yaml:"readTimeout"`
+	Timeout        int `yaml:"timeout"`
+	// Proxy 代理地址，支持 http://、https://、socks5://，为空时使用 HTTP_PROXY 等环境变量，direct 表示不使用代理
+	Proxy string `yaml:"proxy"`
+	// CAFilePaths 额外信任的根证书文件（PEM），用于私有CA签发的服务器证书
+	CAFilePaths []string `yaml:"caFilePaths"`
+	// MinTLSVersion 最低TLS版本：1.0、1.1、1.2、1.3，默认 1.2
+	MinTLSVersion string `yaml:"minTLSVersion"`
+	// ClientCertFile、ClientKeyFile 客户端证书和私钥（PEM），配置后向服务器出示证书进行双向TLS认证
+	ClientCertFile string `yaml:"clientCertFile"`
+	ClientKeyFile  string `yaml:"clientKeyFile"`
 }
 
 // ServerConfig 服务器相关配置
@@ -33,12 +114,79 @@ type ServerConfig struct {
 	Domain    string `yaml:"domain"`
 	IPsURL    string `yaml:"ipsUrl"`
 	PlanetURL string `yaml:"planetUrl"`
+	// CertPins 服务器证书公钥 SHA-256 固定值（sha256/<base64>），可配置多个用于更换证书；
+	// 配置后访问 ipsUrl、planetUrl 只校验固定值，不再依赖系统根证书
+	CertPins []string `yaml:"certPins"`
+	// Auth 请求签名认证，启用后密钥不再出现在 URL 中
+	Auth ServerAuthConfig `yaml:"auth"`
+	// Resolvers 检测域名使用的解析服务器，为空时使用系统解析器
+	Resolvers              []ResolverConfig `yaml:"resolvers"`
+	ResolverTimeout        int              `yaml:"resolverTimeout"`
+	SystemResolverFallback bool             `yaml:"systemResolverFallback"`
+	// ResolverMode 多个解析器的使用方式：first 依次查询取第一个成功结果，consensus 同时查询并要求达到法定数量一致，
+	// 得票最多的结果有多个时视为失败
+	ResolverMode   string `yaml:"resolverMode"`
+	ResolverQuorum int    `yaml:"resolverQuorum"`
+}
+
+// ServerAuthConfig 请求服务器的认证方式
+type ServerAuthConfig struct {
+	// Mode 为空时使用 URL 中的 key 参数；hmac 时在 Authorization 头中携带 HMAC-SHA256 签名
+	Mode  string `yaml:"mode"`
+	KeyID string `yaml:"keyId"`
+	// Secret 签名密钥，也可通过 SecretFile 从文件读取
+	Secret     string `yaml:"secret"`
+	SecretFile string `yaml:"secretFile"`
+	// ClockSkewWarn 本地时钟与服务器相差超过该秒数时告警，默认 30
+	ClockSkewWarn int `yaml:"clockSkewWarn"`
+}
+
+// ResolverConfig 解析服务器配置
+type ResolverConfig struct {
+	Type       string `yaml:"type"`       // udp、tcp、doh、dot、system
+	Address    string `yaml:"address"`    // 服务器地址，未指定端口时 udp/tcp 使用 53，dot 使用 853
+	URL        string `yaml:"url"`        // DoH 地址，如 https://dns.alidns.com/dns-query
+	Format     string `yaml:"format"`     // DoH 格式：wire（默认）或 json
+	Bootstrap  string `yaml:"bootstrap"`  // DoH 服务器 IP，用于绕过对 DoH 域名的解析
+	ServerName string `yaml:"serverName"` // DoT 证书校验主机名，为空时使用 address 中的主机
 }
 
 // ZeroTierConfig 结构体（ZeroTier 相关配置）
 type ZeroTierConfig struct {
 	ServiceName string `yaml:"serviceName"`
 	PlanetPath  string `yaml:"planetPath"`
+	// Controller 服务控制方式：auto、windows、systemd、procd、openrc、sysv、docker
+	Controller string            `yaml:"controller"`
+	Docker     DockerConfig      `yaml:"docker"`
+	API        ZeroTierAPIConfig `yaml:"api"`
+	PlanetPin  PlanetPinConfig   `yaml:"planetPin"`
+	// AllowDowngrade 允许安装时间戳早于当前planet的文件，用于有意回退
+	AllowDowngrade bool `yaml:"allowDowngrade"`
+}
+
+// PlanetPinConfig planet 签名固定配置，未配置 worldId/updateKey 时以已安装的planet为准并记录到 pinPath
+type PlanetPinConfig struct {
+	WorldID   uint64 `yaml:"worldId"`
+	UpdateKey string `yaml:"updateKey"`
+	PinPath   string `yaml:"pinPath"`
+}
+
+// ZeroTierAPIConfig ZeroTier 本地 JSON API 配置，用于重启后验证节点状态
+type ZeroTierAPIConfig struct {
+	URL           string `yaml:"url"`
+	AuthTokenPath string `yaml:"authTokenPath"`
+	// VerifyTimeout 重启后等待节点上线的秒数，小于等于 0 时不验证
+	VerifyTimeout int `yaml:"verifyTimeout"`
+}
+
+// DockerConfig Docker 容器相关配置（controller 为 docker 时使用）
+type DockerConfig struct {
+	// Socket Docker Engine 的 unix 套接字，默认 /var/run/docker.sock
+	Socket       string `yaml:"socket"`
+	Container    string `yaml:"container"`
+	StopTimeout  int    `yaml:"stopTimeout"`
+	StartTimeout int    `yaml:"startTimeout"`
+	WaitHealthy  bool   `yaml:"waitHealthy"`
 }
 
 // Service 配置（扩展服务）
@@ -76,6 +224,23 @@ func FixRelativePaths(cfg interface{}, absoluteDir string) error {
 			}
 		}
 
+		// 如果字段名称以File结尾且是字符串类型，为可选文件，未配置时保持为空
+		if strings.HasSuffix(fieldType.Name, "File") && field.Kind() == reflect.String {
+			if path := field.String(); path != "" && !filepath.IsAbs(path) {
+				field.SetString(filepath.Join(absoluteDir, path))
+			}
+		}
+
+		// 如果字段名称以Paths结尾且是字符串切片，逐个处理
+		if strings.HasSuffix(fieldType.Name, "Paths") && field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String {
+			for j := 0; j < field.Len(); j++ {
+				item := field.Index(j)
+				if path := item.String(); path != "" && !filepath.IsAbs(path) {
+					item.SetString(filepath.Join(absoluteDir, path))
+				}
+			}
+		}
+
 		// 如果字段是结构体类型，递归处理
 		if field.Kind() == reflect.Struct {
 			// 获取结构体指针
@@ -89,6 +254,28 @@ func FixRelativePaths(cfg interface{}, absoluteDir string) error {
 	return nil
 }
 
+// applyDefaults 为未配置的项设置默认值，须在修复相对路径之前调用，否则未配置的路径会被当作可执行文件所在目录
+func applyDefaults(cfg *Config) {
+	app := &cfg.AppConfig
+	if app.HTTPCachePath == "" {
+		app.HTTPCachePath = "http_cache"
+	}
+	if app.PlanetHistoryPath == "" {
+		app.PlanetHistoryPath = "planet_history"
+	}
+	if app.StateFilePath == "" {
+		app.StateFilePath = "state.json"
+	}
+	zt := &cfg.ZeroTierConfig
+	if zt.API.AuthTokenPath == "" {
+		// authtoken.secret 与 planet 位于同一数据目录
+		zt.API.AuthTokenPath = filepath.Join(filepath.Dir(zt.PlanetPath), "authtoken.secret")
+	}
+	if zt.PlanetPin.PinPath == "" {
+		zt.PlanetPin.PinPath = "planet_pin.json"
+	}
+}
+
 // LoadConfig 读取 YAML 配置文件并修复路径
 func LoadConfig(configPath string) (*Config, error) {
 	// 获取可执行文件所在目录
@@ -118,6 +305,8 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("解析 YAML 失败: %v", err)
 	}
 
+	applyDefaults(&config)
+
 	// 修复相对路径
 	if err := FixRelativePaths(&config, absoluteDir); err != nil {
 		return nil, fmt.Errorf("修复路径失败: %v", err)
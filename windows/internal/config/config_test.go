@@ -0,0 +1,51 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestDefaultsAndRelativePaths 未配置的路径使用默认值，可选文件保持为空
+func TestDefaultsAndRelativePaths(t *testing.T) {
+	dir := t.TempDir()
+	var cfg Config
+	data := []byte(`
+app:
+  ipFilePath: "ips.txt"
+zerotier:
+  planetPath: "/var/lib/zerotier-one/planet"
+http:
+  clientCertFile: "certs/client.crt"
+  caFilePaths: ["ca.pem", ""]
+`)
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	applyDefaults(&cfg)
+	if err := FixRelativePaths(&cfg, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, got, want string
+	}{
+		{"ipFilePath", cfg.AppConfig.IPFilePath, filepath.Join(dir, "ips.txt")},
+		{"httpCachePath", cfg.AppConfig.HTTPCachePath, filepath.Join(dir, "http_cache")},
+		{"planetHistoryPath", cfg.AppConfig.PlanetHistoryPath, filepath.Join(dir, "planet_history")},
+		{"stateFilePath", cfg.AppConfig.StateFilePath, filepath.Join(dir, "state.json")},
+		{"pinPath", cfg.ZeroTierConfig.PlanetPin.PinPath, filepath.Join(dir, "planet_pin.json")},
+		{"authTokenPath", cfg.ZeroTierConfig.API.AuthTokenPath, filepath.Join("/var/lib/zerotier-one", "authtoken.secret")},
+		{"clientCertFile", cfg.HTTPConfig.ClientCertFile, filepath.Join(dir, "certs/client.crt")},
+		{"clientKeyFile", cfg.HTTPConfig.ClientKeyFile, ""},
+		{"secretFile", cfg.ServerConfig.Auth.SecretFile, ""},
+		{"caFilePaths[0]", cfg.HTTPConfig.CAFilePaths[0], filepath.Join(dir, "ca.pem")},
+		{"caFilePaths[1]", cfg.HTTPConfig.CAFilePaths[1], ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s 为 %q，期望 %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
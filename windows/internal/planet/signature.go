@@ -0,0 +1,107 @@
+package planet
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// signedOffset 签名在原始数据中的偏移：类型(1) + ID(8) + 时间戳(8) + 更新公钥(64)
+const signedOffset = 1 + 8 + 8 + PublicKeyLen
+
+// SigningMessage 返回签名覆盖的数据，与 ZeroTier World::serialize(forSign=true) 一致：
+// 前后各加 8 字节标记，且不包含签名本身
+func (w *World) SigningMessage() []byte {
+	msg := make([]byte, 0, len(w.raw)-SignatureLen+16)
+	msg = append(msg, bytes.Repeat([]byte{0x7f}, 8)...)
+	msg = append(msg, w.raw[:signedOffset]...)
+	msg = append(msg, w.raw[signedOffset+SignatureLen:]...)
+	msg = append(msg, bytes.Repeat([]byte{0xf7}, 8)...)
+	return msg
+}
+
+// Verify 使用指定的 C25519 公钥校验 World 签名
+func (w *World) Verify(key [PublicKeyLen]byte) error {
+	if w.Signature == [SignatureLen]byte{} {
+		return errors.New("planet未签名")
+	}
+	// C25519 签名 = Ed25519(SHA512(msg)[:32]) + SHA512(msg)[:32]
+	digest := sha512.Sum512(w.SigningMessage())
+	if !bytes.Equal(w.Signature[64:], digest[:32]) {
+		return errors.New("planet签名摘要不匹配")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key[32:]), digest[:32], w.Signature[:64]) {
+		return errors.New("planet签名无效")
+	}
+	return nil
+}
+
+// Pin 固定的 World ID 与更新签名公钥
+type Pin struct {
+	WorldID   uint64 `json:"worldId"`
+	UpdateKey string `json:"updateKey"`
+}
+
+// PinOf 返回 World 当前的 ID 与更新签名公钥
+func PinOf(w *World) *Pin {
+	return &Pin{WorldID: w.ID, UpdateKey: hex.EncodeToString(w.UpdateSigningKey[:])}
+}
+
+// Key 解析十六进制公钥
+func (p *Pin) Key() ([PublicKeyLen]byte, error) {
+	var key [PublicKeyLen]byte
+	b, err := hex.DecodeString(p.UpdateKey)
+	if err != nil || len(b) != PublicKeyLen {
+		return key, fmt.Errorf("无效的更新签名公钥: %q", p.UpdateKey)
+	}
+	copy(key[:], b)
+	return key, nil
+}
+
+// VerifyPinned 校验 World 属于固定的 World ID 且由固定公钥签名
+func (w *World) VerifyPinned(pin *Pin) error {
+	if w.ID != pin.WorldID {
+		return fmt.Errorf("World ID 不匹配: 期望 %d，实际 %d", pin.WorldID, w.ID)
+	}
+	key, err := pin.Key()
+	if err != nil {
+		return err
+	}
+	if err := w.Verify(key); err != nil {
+		return fmt.Errorf("未通过固定公钥校验: %v", err)
+	}
+	return nil
+}
+
+// LoadPin 读取固定信息文件，文件不存在时返回 nil
+func LoadPin(path string) (*Pin, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取planet固定信息失败: %w", err)
+	}
+	var pin Pin
+	if err := json.Unmarshal(data, &pin); err != nil {
+		return nil, fmt.Errorf("解析planet固定信息失败: %w", err)
+	}
+	return &pin, nil
+}
+
+// SavePin 保存固定信息文件
+func SavePin(path string, pin *Pin) error {
+	data, err := json.MarshalIndent(pin, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化planet固定信息失败: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("保存planet固定信息失败: %w", err)
+	}
+	return nil
+}
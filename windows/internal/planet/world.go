@@ -0,0 +1,292 @@
+// Package planet 解析 ZeroTier planet/moon（World）二进制文件
+package planet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+)
+
+// WorldType World 类型
+type WorldType uint8
+
+const (
+	TypePlanet WorldType = 1
+	TypeMoon   WorldType = 127
+)
+
+const (
+	PublicKeyLen = 64 // C25519 公钥长度（32 字节 Curve25519 + 32 字节 Ed25519）
+	SignatureLen = 96 // C25519 签名长度（64 字节 Ed25519 签名 + 32 字节消息摘要）
+
+	identityTypeC25519 = 0
+	addressLen         = 5
+	maxWorldSize       = 4096
+)
+
+// String 返回 World 类型名称
+func (t WorldType) String() string {
+	switch t {
+	case TypePlanet:
+		return "planet"
+	case TypeMoon:
+		return "moon"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(t))
+	}
+}
+
+// Identity 根节点身份（仅公钥部分）
+type Identity struct {
+	Address   uint64 // 40 位 ZeroTier 地址
+	PublicKey [PublicKeyLen]byte
+}
+
+// String 返回与 zerotier-idtool 一致的公开身份字符串
+func (id Identity) String() string {
+	return fmt.Sprintf("%010x:%d:%s", id.Address, identityTypeC25519, hex.EncodeToString(id.PublicKey[:]))
+}
+
+// Root 根节点及其固定地址
+type Root struct {
+	Identity        Identity
+	StableEndpoints []netip.AddrPort
+}
+
+// World planet/moon 文件内容
+type World struct {
+	Type             WorldType
+	ID               uint64
+	Timestamp        uint64 // 毫秒时间戳，同时作为版本号
+	UpdateSigningKey [PublicKeyLen]byte
+	Signature        [SignatureLen]byte
+	Roots            []Root
+
+	raw []byte // 原始数据，用于重建签名内容
+}
+
+// Time 返回 World 时间戳对应的时间
+func (w *World) Time() time.Time {
+	return time.UnixMilli(int64(w.Timestamp))
+}
+
+// IPs 返回所有根节点固定地址中的 IP（去重，保持出现顺序）
+func (w *World) IPs() []netip.Addr {
+	var ips []netip.Addr
+	seen := make(map[netip.Addr]bool)
+	for _, root := range w.Roots {
+		for _, ep := range root.StableEndpoints {
+			ip := ep.Addr().Unmap()
+			if !seen[ip] {
+				seen[ip] = true
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// String 返回便于阅读的多行描述
+func (w *World) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "类型: %s\n", w.Type)
+	fmt.Fprintf(&b, "ID: %d\n", w.ID)
+	fmt.Fprintf(&b, "时间戳: %d (%s)\n", w.Timestamp, w.Time().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "更新签名公钥: %s\n", hex.EncodeToString(w.UpdateSigningKey[:]))
+	for i, root := range w.Roots {
+		fmt.Fprintf(&b, "根节点%d: %s\n", i+1, root.Identity)
+		for _, ep := range root.StableEndpoints {
+			fmt.Fprintf(&b, "  地址: %s\n", ep)
+		}
+	}
+	return b.String()
+}
+
+// ReadFile 读取并解析 World 文件
+func ReadFile(path string) (*World, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取planet文件失败: %w", err)
+	}
+	return Parse(data)
+}
+
+// reader 按 ZeroTier 网络字节序读取数据
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) next(n int) ([]byte, error) {
+	if r.pos+n > len(r.data) {
+		return nil, fmt.Errorf("数据在偏移 %d 处意外结束", r.pos)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *reader) uint8() (uint8, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *reader) uint16() (uint16, error) {
+	b, err := r.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b), nil
+}
+
+func (r *reader) uint64() (uint64, error) {
+	b, err := r.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// Parse 解析 World 二进制数据
+func Parse(data []byte) (*World, error) {
+	if len(data) > maxWorldSize {
+		return nil, fmt.Errorf("planet文件过大: %d 字节", len(data))
+	}
+	r := &reader{data: data}
+	w := &World{raw: append([]byte(nil), data...)}
+
+	t, err := r.uint8()
+	if err != nil {
+		return nil, err
+	}
+	w.Type = WorldType(t)
+	if w.Type != TypePlanet && w.Type != TypeMoon {
+		return nil, fmt.Errorf("未知的World类型: %d", t)
+	}
+	if w.ID, err = r.uint64(); err != nil {
+		return nil, err
+	}
+	if w.Timestamp, err = r.uint64(); err != nil {
+		return nil, err
+	}
+	key, err := r.next(PublicKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	copy(w.UpdateSigningKey[:], key)
+	sig, err := r.next(SignatureLen)
+	if err != nil {
+		return nil, err
+	}
+	copy(w.Signature[:], sig)
+
+	numRoots, err := r.uint8()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(numRoots); i++ {
+		root, err := readRoot(r)
+		if err != nil {
+			return nil, fmt.Errorf("解析根节点%d失败: %v", i+1, err)
+		}
+		w.Roots = append(w.Roots, root)
+	}
+
+	if w.Type == TypeMoon {
+		// moon 末尾附带字典长度，目前恒为 0
+		dictLen, err := r.uint16()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.next(int(dictLen)); err != nil {
+			return nil, err
+		}
+	}
+	if r.pos != len(data) {
+		return nil, fmt.Errorf("planet文件末尾存在 %d 字节多余数据", len(data)-r.pos)
+	}
+	return w, nil
+}
+
+// readRoot 解析一个根节点：身份 + 固定地址列表
+func readRoot(r *reader) (Root, error) {
+	var root Root
+
+	addr, err := r.next(addressLen)
+	if err != nil {
+		return root, err
+	}
+	for _, b := range addr {
+		root.Identity.Address = root.Identity.Address<<8 | uint64(b)
+	}
+	idType, err := r.uint8()
+	if err != nil {
+		return root, err
+	}
+	if idType != identityTypeC25519 {
+		return root, fmt.Errorf("不支持的身份类型: %d", idType)
+	}
+	pub, err := r.next(PublicKeyLen)
+	if err != nil {
+		return root, err
+	}
+	copy(root.Identity.PublicKey[:], pub)
+	privLen, err := r.uint8()
+	if err != nil {
+		return root, err
+	}
+	if privLen != 0 {
+		return root, fmt.Errorf("planet中不应包含根节点私钥")
+	}
+
+	numEndpoints, err := r.uint8()
+	if err != nil {
+		return root, err
+	}
+	for i := 0; i < int(numEndpoints); i++ {
+		ep, ok, err := readInetAddress(r)
+		if err != nil {
+			return root, err
+		}
+		if ok {
+			root.StableEndpoints = append(root.StableEndpoints, ep)
+		}
+	}
+	return root, nil
+}
+
+// readInetAddress 解析 InetAddress，空地址返回 ok=false
+func readInetAddress(r *reader) (netip.AddrPort, bool, error) {
+	family, err := r.uint8()
+	if err != nil {
+		return netip.AddrPort{}, false, err
+	}
+	var ipLen int
+	switch family {
+	case 0:
+		return netip.AddrPort{}, false, nil
+	case 4:
+		ipLen = 4
+	case 6:
+		ipLen = 16
+	default:
+		return netip.AddrPort{}, false, fmt.Errorf("未知的地址类型: %d", family)
+	}
+	ipBytes, err := r.next(ipLen)
+	if err != nil {
+		return netip.AddrPort{}, false, err
+	}
+	port, err := r.uint16()
+	if err != nil {
+		return netip.AddrPort{}, false, err
+	}
+	ip, _ := netip.AddrFromSlice(ipBytes)
+	return netip.AddrPortFrom(ip, port), true, nil
+}
@@ -0,0 +1,218 @@
+package planet
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testdata/planet 按 mkworld（World::serialize）的格式生成：两个根节点、IPv4/IPv6 固定地址，
+// 由 testSigningSeed 对应的 C25519 更新密钥签名
+const (
+	goldenWorldID   = 1234567890
+	goldenTimestamp = 1700000000000
+	goldenRoot1     = "1122334455:0:"
+	goldenRoot2     = "aabbccddee:0:"
+)
+
+// testSigningSeed 测试用更新签名密钥的 Ed25519 种子
+var testSigningSeed = bytes.Repeat([]byte{0x42}, ed25519.SeedSize)
+
+// testRoot 构造测试 World 使用的根节点
+type testRoot struct {
+	address   uint64
+	key       byte // 公钥每个字节的取值
+	privLen   byte
+	endpoints []string
+}
+
+// buildWorld 按 World::serialize 的字节布局构造 planet 并用 seed 签名，
+// 与 Parse 独立实现，用于生成各种异常文件
+func buildWorld(t *testing.T, seed []byte, roots []testRoot) []byte {
+	t.Helper()
+	priv := ed25519.NewKeyFromSeed(seed)
+	updateKey := testUpdateKey(seed)
+
+	var header, body bytes.Buffer
+	header.WriteByte(byte(TypePlanet))
+	binary.Write(&header, binary.BigEndian, uint64(goldenWorldID))
+	binary.Write(&header, binary.BigEndian, uint64(goldenTimestamp))
+	header.Write(updateKey[:])
+
+	body.WriteByte(byte(len(roots)))
+	for _, root := range roots {
+		var addr [8]byte
+		binary.BigEndian.PutUint64(addr[:], root.address)
+		body.Write(addr[3:])
+		body.WriteByte(identityTypeC25519)
+		body.Write(bytes.Repeat([]byte{root.key}, PublicKeyLen))
+		body.WriteByte(root.privLen)
+		body.Write(bytes.Repeat([]byte{0x01}, int(root.privLen)))
+		body.WriteByte(byte(len(root.endpoints)))
+		for _, s := range root.endpoints {
+			ep := netip.MustParseAddrPort(s)
+			if ep.Addr().Is4() {
+				body.WriteByte(4)
+			} else {
+				body.WriteByte(6)
+			}
+			body.Write(ep.Addr().AsSlice())
+			binary.Write(&body, binary.BigEndian, ep.Port())
+		}
+	}
+
+	// C25519 签名：对 SHA512(0x7f*8 + 头部 + 根节点 + 0xf7*8) 的前 32 字节做 Ed25519 签名，再附上该摘要
+	var msg bytes.Buffer
+	msg.Write(bytes.Repeat([]byte{0x7f}, 8))
+	msg.Write(header.Bytes())
+	msg.Write(body.Bytes())
+	msg.Write(bytes.Repeat([]byte{0xf7}, 8))
+	digest := sha512.Sum512(msg.Bytes())
+	sig := append(ed25519.Sign(priv, digest[:32]), digest[:32]...)
+
+	var out bytes.Buffer
+	out.Write(header.Bytes())
+	out.Write(sig)
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+// testUpdateKey 返回 seed 对应的 C25519 公钥，Curve25519 部分不参与签名校验，用固定值填充
+func testUpdateKey(seed []byte) [PublicKeyLen]byte {
+	var key [PublicKeyLen]byte
+	copy(key[:32], bytes.Repeat([]byte{0x33}, 32))
+	copy(key[32:], ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+	return key
+}
+
+// goldenRoots testdata/planet 的根节点
+var goldenRoots = []testRoot{
+	{address: 0x1122334455, key: 0xa1, endpoints: []string{"203.0.113.10:9993", "[2001:db8::10]:9993"}},
+	{address: 0xaabbccddee, key: 0xb2, endpoints: []string{"198.51.100.7:9993"}},
+}
+
+func readGolden(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/planet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseGolden(t *testing.T) {
+	data := readGolden(t)
+	if !bytes.Equal(data, buildWorld(t, testSigningSeed, goldenRoots)) {
+		t.Fatal("testdata/planet 与构造结果不一致")
+	}
+	w, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Type != TypePlanet || w.ID != goldenWorldID || w.Timestamp != goldenTimestamp {
+		t.Errorf("头部为 type=%v id=%d ts=%d", w.Type, w.ID, w.Timestamp)
+	}
+	if len(w.Roots) != 2 {
+		t.Fatalf("根节点数为 %d，期望 2", len(w.Roots))
+	}
+	if id := w.Roots[0].Identity.String(); !strings.HasPrefix(id, goldenRoot1) || !strings.HasSuffix(id, strings.Repeat("a1", PublicKeyLen)) {
+		t.Errorf("根节点1身份为 %s", id)
+	}
+	if id := w.Roots[1].Identity.String(); !strings.HasPrefix(id, goldenRoot2) {
+		t.Errorf("根节点2身份为 %s", id)
+	}
+	var endpoints []string
+	for _, root := range w.Roots {
+		for _, ep := range root.StableEndpoints {
+			endpoints = append(endpoints, ep.String())
+		}
+	}
+	want := "203.0.113.10:9993 [2001:db8::10]:9993 198.51.100.7:9993"
+	if got := strings.Join(endpoints, " "); got != want {
+		t.Errorf("固定地址为 %s，期望 %s", got, want)
+	}
+	if err := w.Verify(w.UpdateSigningKey); err != nil {
+		t.Errorf("签名校验失败: %v", err)
+	}
+	pin := PinOf(w)
+	if err := w.VerifyPinned(pin); err != nil {
+		t.Errorf("固定公钥校验失败: %v", err)
+	}
+	if key := testUpdateKey(testSigningSeed); pin.UpdateKey != hex.EncodeToString(key[:]) {
+		t.Errorf("更新签名公钥为 %s", pin.UpdateKey)
+	}
+}
+
+func TestParseRejectsMalformed(t *testing.T) {
+	golden := readGolden(t)
+	withPrivateKey := buildWorld(t, testSigningSeed, []testRoot{{address: 1, key: 0xa1, privLen: 64}})
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"空文件", nil, "意外结束"},
+		{"截断的头部", golden[:40], "意外结束"},
+		{"截断的根节点", golden[:len(golden)-3], "意外结束"},
+		{"末尾多余数据", append(append([]byte(nil), golden...), 0), "多余数据"},
+		{"包含私钥", withPrivateKey, "私钥"},
+		{"未知类型", append([]byte{2}, golden[1:]...), "未知的World类型"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.data)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: 错误为 %v，期望包含 %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	golden := readGolden(t)
+	mutate := func(offset int) *World {
+		data := append([]byte(nil), golden...)
+		data[offset] ^= 0x01
+		w, err := Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+	good, err := Parse(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 签名中的 Ed25519 部分
+	if w := mutate(signedOffset + 10); w.Verify(good.UpdateSigningKey) == nil {
+		t.Error("翻转签名字节后仍通过校验")
+	}
+	// 签名中的摘要部分
+	if w := mutate(signedOffset + 70); w.Verify(good.UpdateSigningKey) == nil {
+		t.Error("翻转签名摘要字节后仍通过校验")
+	}
+	// 被签名的根节点端口
+	if w := mutate(len(golden) - 1); w.Verify(good.UpdateSigningKey) == nil {
+		t.Error("修改根节点端口后仍通过校验")
+	}
+	// 其他密钥
+	other := buildWorld(t, bytes.Repeat([]byte{0x24}, ed25519.SeedSize), goldenRoots)
+	w, err := Parse(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.VerifyPinned(PinOf(good)) == nil {
+		t.Error("其他密钥签名的planet通过了固定公钥校验")
+	}
+	// 未签名
+	unsigned := *good
+	unsigned.Signature = [SignatureLen]byte{}
+	if unsigned.Verify(good.UpdateSigningKey) == nil {
+		t.Error("未签名的planet通过了校验")
+	}
+}
@@ -0,0 +1,127 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Consensus 同时查询多个解析器，只有达到法定数量的解析器返回相同 IP 集合时才采用该结果
+type Consensus struct {
+	Resolvers []Resolver
+	Quorum    int
+}
+
+// NewConsensus 创建多解析器共识，quorum 小于等于 0 时取多数
+func NewConsensus(resolvers []Resolver, quorum int) (*Consensus, error) {
+	if quorum <= 0 {
+		quorum = len(resolvers)/2 + 1
+	}
+	if quorum > len(resolvers) {
+		return nil, fmt.Errorf("法定数量 %d 超过解析器数量 %d", quorum, len(resolvers))
+	}
+	return &Consensus{Resolvers: resolvers, Quorum: quorum}, nil
+}
+
+// Name 返回解析器描述
+func (c *Consensus) Name() string {
+	names := make([]string, len(c.Resolvers))
+	for i, r := range c.Resolvers {
+		names[i] = r.Name()
+	}
+	return fmt.Sprintf("consensus(%d/%d)[%s]", c.Quorum, len(c.Resolvers), strings.Join(names, ", "))
+}
+
+// answerKey 返回与顺序无关的 IP 集合标识
+func answerKey(ips []netip.Addr) string {
+	seen := make(map[netip.Addr]bool)
+	var addrs []netip.Addr
+	for _, ip := range ips {
+		ip = ip.Unmap()
+		if !seen[ip] {
+			seen[ip] = true
+			addrs = append(addrs, ip)
+		}
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Less(addrs[j]) })
+	fields := make([]string, len(addrs))
+	for i, ip := range addrs {
+		fields[i] = ip.String()
+	}
+	return strings.Join(fields, ",")
+}
+
+// Lookup 并发查询全部解析器，返回得票最多且达到法定数量的结果，并记录意见不一致的解析器。
+// 得票最多的结果有多个（平票）时返回错误
+func (c *Consensus) Lookup(ctx context.Context, domain string) (*Result, error) {
+	type answer struct {
+		result *Result
+		err    error
+	}
+	answers := make([]answer, len(c.Resolvers))
+	var wg sync.WaitGroup
+	for i, r := range c.Resolvers {
+		wg.Add(1)
+		go func(i int, r Resolver) {
+			defer wg.Done()
+			result, err := r.Lookup(ctx, domain)
+			answers[i] = answer{result, err}
+		}(i, r)
+	}
+	wg.Wait()
+
+	votes := make(map[string][]int)
+	for i, a := range answers {
+		if a.err == nil {
+			key := answerKey(a.result.IPs)
+			votes[key] = append(votes[key], i)
+		}
+	}
+	// 得票最多的结果不唯一时无法判断哪个正确，不采用任何结果
+	winner, best, tied := "", 0, false
+	for key, voters := range votes {
+		switch {
+		case len(voters) > best:
+			winner, best, tied = key, len(voters), false
+		case len(voters) == best:
+			tied = true
+		}
+	}
+
+	agreed := make(map[int]bool)
+	for _, i := range votes[winner] {
+		agreed[i] = true
+	}
+	for i, a := range answers {
+		if agreed[i] {
+			continue
+		}
+		if a.err != nil {
+			log.Printf("解析器 %s 查询失败: %v", c.Resolvers[i].Name(), a.err)
+		} else {
+			log.Printf("解析器 %s 结果不一致: %s", c.Resolvers[i].Name(), answerKey(a.result.IPs))
+		}
+	}
+
+	if best < c.Quorum {
+		return nil, fmt.Errorf("解析结果未达成一致: 最多 %d 个解析器返回 [%s]，需要 %d 个", best, winner, c.Quorum)
+	}
+	if tied {
+		return nil, fmt.Errorf("解析结果未达成一致: 有多个结果各有 %d 个解析器返回", best)
+	}
+	result := &Result{}
+	for n, i := range votes[winner] {
+		r := answers[i].result
+		if n == 0 {
+			result.IPs = r.IPs
+			result.TTL = r.TTL
+		} else if r.TTL > 0 && (result.TTL == 0 || r.TTL < result.TTL) {
+			result.TTL = r.TTL
+		}
+	}
+	return result, nil
+}
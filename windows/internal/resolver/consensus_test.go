@@ -0,0 +1,144 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeResolver 返回固定结果的解析器
+type fakeResolver struct {
+	name string
+	ips  string // 逗号分隔，为空时返回错误
+	ttl  time.Duration
+}
+
+func (f *fakeResolver) Name() string { return f.name }
+
+func (f *fakeResolver) Lookup(ctx context.Context, domain string) (*Result, error) {
+	if f.ips == "" {
+		return nil, errors.New("模拟查询失败")
+	}
+	result := &Result{TTL: f.ttl}
+	for _, s := range strings.Split(f.ips, ",") {
+		result.IPs = append(result.IPs, netip.MustParseAddr(s))
+	}
+	return result, nil
+}
+
+func TestConsensusLookup(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers []string
+		ttls    []time.Duration
+		quorum  int
+		want    string // 为空表示期望失败
+		wantTTL time.Duration
+	}{
+		{
+			name:    "全部一致",
+			answers: []string{"203.0.113.1", "203.0.113.1", "203.0.113.1"},
+			ttls:    []time.Duration{300 * time.Second, 60 * time.Second, 0},
+			want:    "203.0.113.1",
+			wantTTL: 60 * time.Second,
+		},
+		{
+			name:    "顺序和重复不影响一致",
+			answers: []string{"203.0.113.1,2001:db8::1", "2001:db8::1,203.0.113.1,203.0.113.1", "::ffff:203.0.113.1,2001:db8::1"},
+			want:    "203.0.113.1,2001:db8::1",
+		},
+		{
+			name:    "多数一致",
+			answers: []string{"203.0.113.1", "203.0.113.2", "203.0.113.1"},
+			want:    "203.0.113.1",
+		},
+		{
+			name:    "查询失败不计票",
+			answers: []string{"203.0.113.1", "", "203.0.113.1"},
+			want:    "203.0.113.1",
+		},
+		{
+			name:    "未达到默认多数",
+			answers: []string{"203.0.113.1", "", ""},
+		},
+		{
+			name:    "意见不一致",
+			answers: []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"},
+		},
+		{
+			name:    "未达到指定法定数量",
+			answers: []string{"203.0.113.1", "203.0.113.1", "203.0.113.2"},
+			quorum:  3,
+		},
+		{
+			name:    "法定数量为1时取多数",
+			answers: []string{"203.0.113.2", "203.0.113.1", "203.0.113.1"},
+			quorum:  1,
+			want:    "203.0.113.1",
+		},
+		{
+			name:    "平票时拒绝",
+			answers: []string{"203.0.113.2", "203.0.113.1", "203.0.113.1", "203.0.113.2"},
+			quorum:  2,
+		},
+		{
+			name:    "法定数量为1时单票平票也拒绝",
+			answers: []string{"203.0.113.2", "203.0.113.1"},
+			quorum:  1,
+		},
+		{
+			name:    "全部失败",
+			answers: []string{"", ""},
+			quorum:  1,
+		},
+	}
+	for _, tt := range tests {
+		var resolvers []Resolver
+		for i, ips := range tt.answers {
+			r := &fakeResolver{name: "r" + string(rune('0'+i)), ips: ips}
+			if i < len(tt.ttls) {
+				r.ttl = tt.ttls[i]
+			}
+			resolvers = append(resolvers, r)
+		}
+		c, err := NewConsensus(resolvers, tt.quorum)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		// 平票结果与查询顺序无关，多次查询避免依赖 map 遍历顺序
+		for n := 0; n < 20; n++ {
+			result, err := c.Lookup(context.Background(), "example.com")
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("%s: 返回 %v，期望失败", tt.name, result.IPs)
+					break
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				break
+			}
+			if got := answerKey(result.IPs); got != tt.want || result.TTL != tt.wantTTL {
+				t.Errorf("%s: 返回 [%s] TTL %v，期望 [%s] TTL %v", tt.name, got, result.TTL, tt.want, tt.wantTTL)
+				break
+			}
+		}
+	}
+}
+
+func TestNewConsensusQuorum(t *testing.T) {
+	resolvers := []Resolver{&fakeResolver{name: "a"}, &fakeResolver{name: "b"}, &fakeResolver{name: "c"}, &fakeResolver{name: "d"}}
+	for quorum, want := range map[int]int{0: 3, -1: 3, 2: 2, 4: 4} {
+		c, err := NewConsensus(resolvers, quorum)
+		if err != nil || c.Quorum != want {
+			t.Errorf("quorum %d: 法定数量为 %v %v，期望 %d", quorum, c, err, want)
+		}
+	}
+	if _, err := NewConsensus(resolvers, 5); err == nil {
+		t.Error("法定数量超过解析器数量时未返回错误")
+	}
+}
@@ -0,0 +1,134 @@
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// DNSResolver 直接向指定 DNS 服务器发送 UDP/TCP 查询
+type DNSResolver struct {
+	Network string // udp 或 tcp
+	Address string // host:port
+	Timeout time.Duration
+}
+
+// NewDNSResolver 创建 DNS 服务器解析器，地址未指定端口时使用 53
+func NewDNSResolver(network, address string, timeout time.Duration) (*DNSResolver, error) {
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("不支持的DNS协议: %s", network)
+	}
+	addr, err := withDefaultPort(address, "53")
+	if err != nil {
+		return nil, err
+	}
+	return &DNSResolver{Network: network, Address: addr, Timeout: timeout}, nil
+}
+
+// withDefaultPort 为未指定端口的地址补充默认端口
+func withDefaultPort(address, port string) (string, error) {
+	if address == "" {
+		return "", fmt.Errorf("未配置服务器地址")
+	}
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address, nil
+	}
+	// 去掉 IPv6 地址可能带的方括号后重新拼接
+	host := address
+	if len(host) > 1 && host[0] == '[' && host[len(host)-1] == ']' {
+		host = host[1 : len(host)-1]
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// Name 返回解析器描述
+func (r *DNSResolver) Name() string {
+	return r.Network + "://" + r.Address
+}
+
+// Lookup 查询域名的 A/AAAA 记录
+func (r *DNSResolver) Lookup(ctx context.Context, domain string) (*Result, error) {
+	return lookupWire(ctx, domain, r.Timeout, r.exchange)
+}
+
+// exchange 发送查询，UDP 响应被截断时改用 TCP
+func (r *DNSResolver) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	if r.Network == "tcp" {
+		return exchangeTCP(ctx, r.Address, query)
+	}
+	resp, err := exchangeUDP(ctx, r.Address, query)
+	if err != nil {
+		return nil, err
+	}
+	flags := binary.BigEndian.Uint16(resp[2:])
+	if flags&flagTC != 0 {
+		return exchangeTCP(ctx, r.Address, query)
+	}
+	return resp, nil
+}
+
+// exchangeUDP 通过 UDP 发送查询，忽略 ID 不匹配的报文
+func exchangeUDP(ctx context.Context, address string, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("连接DNS服务器失败: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, fmt.Errorf("发送DNS查询失败: %w", err)
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("读取DNS响应失败: %w", err)
+		}
+		if n >= headerLen && buf[0] == query[0] && buf[1] == query[1] {
+			return append([]byte(nil), buf[:n]...), nil
+		}
+	}
+}
+
+// exchangeTCP 通过 TCP 发送查询
+func exchangeTCP(ctx context.Context, address string, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("连接DNS服务器失败: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return exchangeStream(conn, query)
+}
+
+// exchangeStream 在流式连接上发送带 2 字节长度前缀的查询并读取响应（TCP/DoT 共用）
+func exchangeStream(conn io.ReadWriter, query []byte) ([]byte, error) {
+	frame := binary.BigEndian.AppendUint16(make([]byte, 0, len(query)+2), uint16(len(query)))
+	frame = append(frame, query...)
+	if _, err := conn.Write(frame); err != nil {
+		return nil, fmt.Errorf("发送DNS查询失败: %w", err)
+	}
+	var lenBuf [2]byte
+	if _, err := io.ReadFull(conn, lenBuf[:]); err != nil {
+		return nil, fmt.Errorf("读取DNS响应失败: %w", err)
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, fmt.Errorf("读取DNS响应失败: %w", err)
+	}
+	if len(resp) < headerLen {
+		return nil, errors.New("DNS响应过短")
+	}
+	return resp, nil
+}
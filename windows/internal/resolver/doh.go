@@ -0,0 +1,181 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	dohMediaWire = "application/dns-message"
+	dohMediaJSON = "application/dns-json"
+	dohMaxBody   = 64 * 1024
+)
+
+// DoHResolver DNS-over-HTTPS（RFC 8484）解析器，支持 wire 与 JSON 两种格式
+type DoHResolver struct {
+	URL     string
+	Format  string // wire 或 json
+	Timeout time.Duration
+	Client  *http.Client
+}
+
+// NewDoHResolver 创建 DoH 解析器，bootstrap 不为空时直接连接该 IP，无需先解析 DoH 服务器域名
+func NewDoHResolver(endpoint, format, bootstrap string, timeout time.Duration) (*DoHResolver, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("无效的DoH地址: %q", endpoint)
+	}
+	switch format {
+	case "":
+		format = "wire"
+	case "wire", "json":
+	default:
+		return nil, fmt.Errorf("不支持的DoH格式: %s", format)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // 直接连接DoH服务器，不经过环境变量中的代理
+	if bootstrap != "" {
+		ip, err := netip.ParseAddr(bootstrap)
+		if err != nil {
+			return nil, fmt.Errorf("无效的bootstrap地址: %q", bootstrap)
+		}
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			_, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		}
+	}
+	return &DoHResolver{
+		URL:     endpoint,
+		Format:  format,
+		Timeout: timeout,
+		Client:  &http.Client{Transport: transport},
+	}, nil
+}
+
+// Name 返回解析器描述
+func (r *DoHResolver) Name() string {
+	return "doh(" + r.Format + ")://" + r.URL
+}
+
+// Lookup 查询域名的 A/AAAA 记录
+func (r *DoHResolver) Lookup(ctx context.Context, domain string) (*Result, error) {
+	if r.Format == "json" {
+		return r.lookupJSON(ctx, domain)
+	}
+	return lookupWire(ctx, domain, r.Timeout, r.exchange)
+}
+
+// do 发送请求并读取响应体
+func (r *DoHResolver) do(req *http.Request, accept string) ([]byte, error) {
+	req.Header.Set("Accept", accept)
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("DoH请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH服务器返回状态码: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dohMaxBody))
+	if err != nil {
+		return nil, fmt.Errorf("读取DoH响应失败: %w", err)
+	}
+	return body, nil
+}
+
+// exchange 以 POST 方式发送 wire 格式查询
+func (r *DoHResolver) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(query))
+	if err != nil {
+		return nil, fmt.Errorf("创建DoH请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", dohMediaWire)
+	return r.do(req, dohMediaWire)
+}
+
+// dohJSONResponse JSON 格式响应
+type dohJSONResponse struct {
+	Status int  `json:"Status"`
+	TC     bool `json:"TC"`
+	Answer []struct {
+		Type uint16 `json:"type"`
+		TTL  uint32 `json:"TTL"`
+		Data string `json:"data"`
+	} `json:"Answer"`
+}
+
+// lookupJSON 以 JSON 格式分别查询 A 和 AAAA 记录
+func (r *DoHResolver) lookupJSON(ctx context.Context, domain string) (*Result, error) {
+	result := &Result{}
+	first := true
+	for _, qtype := range []uint16{TypeA, TypeAAAA} {
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			return nil, fmt.Errorf("无效的DoH地址: %w", err)
+		}
+		q := u.Query()
+		q.Set("name", domain)
+		q.Set("type", strconv.Itoa(int(qtype)))
+		u.RawQuery = q.Encode()
+
+		qctx, cancel := context.WithTimeout(ctx, r.Timeout)
+		req, err := http.NewRequestWithContext(qctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("创建DoH请求失败: %w", err)
+		}
+		body, err := r.do(req, dohMediaJSON)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		var resp dohJSONResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("解析DoH响应失败: %w", err)
+		}
+		switch resp.Status {
+		case 0:
+		case rcodeNXName:
+			return nil, fmt.Errorf("域名不存在")
+		default:
+			return nil, fmt.Errorf("DNS服务器返回错误码: %d", resp.Status)
+		}
+		if resp.TC {
+			return nil, errTruncated
+		}
+		for _, ans := range resp.Answer {
+			if ans.Type != qtype {
+				continue
+			}
+			ip, err := netip.ParseAddr(ans.Data)
+			if err != nil {
+				return nil, fmt.Errorf("DoH响应包含无效地址 %q", ans.Data)
+			}
+			result.IPs = append(result.IPs, ip)
+			if ttl := time.Duration(ans.TTL) * time.Second; first || ttl < result.TTL {
+				result.TTL = ttl
+				first = false
+			}
+		}
+	}
+	if len(result.IPs) == 0 {
+		return nil, fmt.Errorf("域名 %s 没有A/AAAA记录", domain)
+	}
+	return result, nil
+}
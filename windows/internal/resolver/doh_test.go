@@ -0,0 +1,172 @@
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wireDoHHandler 按 RFC 8484 POST 方式应答：A 记录 203.0.113.10，AAAA 记录 2001:db8::10
+func wireDoHHandler(t *testing.T, mangle func([]byte) []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dohMediaWire || r.Header.Get("Accept") != dohMediaWire {
+			t.Errorf("请求为 %s Content-Type=%q Accept=%q", r.Method, r.Header.Get("Content-Type"), r.Header.Get("Accept"))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		query, _ := io.ReadAll(r.Body)
+		var resp []byte
+		switch binary.BigEndian.Uint16(query[len(query)-4:]) {
+		case TypeA:
+			resp = buildResponse(query, 0, testAnswer{TypeA, 300, []byte{203, 0, 113, 10}})
+		default:
+			resp = buildResponse(query, 0, testAnswer{TypeAAAA, 60, netip.MustParseAddr("2001:db8::10").AsSlice()})
+		}
+		if mangle != nil {
+			resp = mangle(resp)
+		}
+		w.Header().Set("Content-Type", dohMediaWire)
+		w.Write(resp)
+	}
+}
+
+func TestDoHWire(t *testing.T) {
+	srv := httptest.NewServer(wireDoHHandler(t, nil))
+	defer srv.Close()
+
+	r, err := NewDoHResolver(srv.URL+"/dns-query", "", "", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Lookup(context.Background(), "zt.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.IPs) != 2 || result.IPs[0].String() != "203.0.113.10" || result.IPs[1].String() != "2001:db8::10" {
+		t.Errorf("解析结果为 %v", result.IPs)
+	}
+	if result.TTL != time.Minute {
+		t.Errorf("TTL 为 %v，期望 1m", result.TTL)
+	}
+}
+
+func TestDoHJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("Accept") != dohMediaJSON {
+			t.Errorf("请求为 %s Accept=%q", r.Method, r.Header.Get("Accept"))
+		}
+		if r.URL.Query().Get("name") != "zt.example.com" || r.URL.Query().Get("ct") != "x" {
+			t.Errorf("查询参数为 %s", r.URL.RawQuery)
+		}
+		resp := map[string]interface{}{"Status": 0, "TC": false}
+		switch r.URL.Query().Get("type") {
+		case "1":
+			resp["Answer"] = []map[string]interface{}{
+				{"type": 5, "TTL": 10, "data": "alias.example.com."},
+				{"type": 1, "TTL": 120, "data": "203.0.113.10"},
+			}
+		case "28":
+			resp["Answer"] = []map[string]interface{}{{"type": 28, "TTL": 30, "data": "2001:db8::10"}}
+		}
+		w.Header().Set("Content-Type", dohMediaJSON)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	// 已有的查询参数需要保留
+	r, err := NewDoHResolver(srv.URL+"/resolve?ct=x", "json", "", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Lookup(context.Background(), "zt.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.IPs) != 2 || result.TTL != 30*time.Second {
+		t.Errorf("解析结果为 %v TTL %v", result.IPs, result.TTL)
+	}
+}
+
+func TestDoHErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{"非200状态码", "wire", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		}, "状态码: 502"},
+		{"截断的wire响应", "wire", wireDoHHandler(t, func(b []byte) []byte { return b[:len(b)-3] }), "越界"},
+		{"无效的wire响应", "wire", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<html>not dns</html>"))
+		}, "DNS响应"},
+		{"截断标志", "wire", wireDoHHandler(t, func(b []byte) []byte {
+			binary.BigEndian.PutUint16(b[2:], binary.BigEndian.Uint16(b[2:])|flagTC)
+			return b
+		}), "截断"},
+		{"无效的JSON", "json", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"Status":0,"Answer":[`))
+		}, "解析DoH响应失败"},
+		{"JSON错误码", "json", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"Status":3}`))
+		}, "域名不存在"},
+		{"JSON无效地址", "json", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"Status":0,"Answer":[{"type":1,"TTL":60,"data":"not-an-ip"}]}`))
+		}, "无效地址"},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(tt.handler)
+		r, err := NewDoHResolver(srv.URL+"/dns-query", tt.format, "", time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = r.Lookup(context.Background(), "zt.example.com")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: 错误为 %v，期望包含 %q", tt.name, err, tt.want)
+		}
+		srv.Close()
+	}
+}
+
+// TestDoHBootstrap 配置 bootstrap 后直接连接该 IP，证书仍按 URL 中的域名校验
+func TestDoHBootstrap(t *testing.T) {
+	srv := httptest.NewTLSServer(wireDoHHandler(t, nil))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// httptest 证书包含 example.com，该域名不会被解析，只能通过 bootstrap 地址连接
+	endpoint := "https://example.com:" + u.Port() + "/dns-query"
+	r, err := NewDoHResolver(endpoint, "wire", "127.0.0.1", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	r.Client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{RootCAs: pool}
+
+	result, err := r.Lookup(context.Background(), "zt.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.IPs) != 2 {
+		t.Errorf("解析结果为 %v", result.IPs)
+	}
+
+	if _, err := NewDoHResolver(endpoint, "wire", "not-an-ip", time.Second); err == nil {
+		t.Error("无效的bootstrap地址未返回错误")
+	}
+}
@@ -0,0 +1,103 @@
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// DoTResolver DNS-over-TLS（RFC 7858）解析器，在多次检测之间复用同一个 TLS 连接
+type DoTResolver struct {
+	Address    string // host:port，默认端口 853
+	ServerName string // 证书校验及 SNI 使用的主机名
+	Timeout    time.Duration
+	TLSConfig  *tls.Config
+
+	mu   sync.Mutex
+	conn *tls.Conn
+}
+
+// NewDoTResolver 创建 DoT 解析器，serverName 为空时使用地址中的主机部分校验证书
+func NewDoTResolver(address, serverName string, timeout time.Duration) (*DoTResolver, error) {
+	addr, err := withDefaultPort(address, "853")
+	if err != nil {
+		return nil, err
+	}
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(addr)
+	}
+	return &DoTResolver{
+		Address:    addr,
+		ServerName: serverName,
+		Timeout:    timeout,
+		TLSConfig: &tls.Config{
+			ServerName: serverName,
+			MinVersion: tls.VersionTLS12,
+		},
+	}, nil
+}
+
+// Name 返回解析器描述
+func (r *DoTResolver) Name() string {
+	return "dot://" + r.Address + "#" + r.ServerName
+}
+
+// Lookup 查询域名的 A/AAAA 记录
+func (r *DoTResolver) Lookup(ctx context.Context, domain string) (*Result, error) {
+	return lookupWire(ctx, domain, r.Timeout, r.exchange)
+}
+
+// dial 建立 TLS 连接并完成握手（含证书及主机名校验）
+func (r *DoTResolver) dial(ctx context.Context) (*tls.Conn, error) {
+	d := tls.Dialer{Config: r.TLSConfig}
+	conn, err := d.DialContext(ctx, "tcp", r.Address)
+	if err != nil {
+		return nil, fmt.Errorf("连接DoT服务器失败: %w", err)
+	}
+	return conn.(*tls.Conn), nil
+}
+
+// exchange 在复用的连接上发送查询，连接已被服务器关闭时重新连接并重试一次
+func (r *DoTResolver) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		reused := r.conn != nil
+		if !reused {
+			conn, err := r.dial(ctx)
+			if err != nil {
+				return nil, err
+			}
+			r.conn = conn
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			r.conn.SetDeadline(deadline)
+		}
+		resp, err := exchangeStream(r.conn, query)
+		if err == nil {
+			r.conn.SetDeadline(time.Time{})
+			return resp, nil
+		}
+		r.conn.Close()
+		r.conn = nil
+		if !reused || attempt > 0 || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+}
+
+// Close 关闭复用的连接
+func (r *DoTResolver) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
@@ -0,0 +1,179 @@
+package resolver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// dotServer 本地 DoT 服务器，每个连接应答 perConn 个查询后关闭
+type dotServer struct {
+	addr    string
+	pool    *x509.CertPool
+	conns   int32
+	queries int32
+}
+
+func startDoTServer(t *testing.T, perConn int) *dotServer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dns.example"},
+		DNSNames:     []string{"dns.example"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &dotServer{addr: ln.Addr().String(), pool: x509.NewCertPool()}
+	s.pool.AddCert(cert)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&s.conns, 1)
+			go s.serve(conn, perConn)
+		}
+	}()
+	return s
+}
+
+// serve 按 RFC 7858 的长度前缀格式应答：A 记录 203.0.113.10，AAAA 记录 2001:db8::10
+func (s *dotServer) serve(conn net.Conn, perConn int) {
+	defer conn.Close()
+	for i := 0; i < perConn; i++ {
+		var lenBuf [2]byte
+		if _, err := io.ReadFull(conn, lenBuf[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		atomic.AddInt32(&s.queries, 1)
+		var resp []byte
+		switch binary.BigEndian.Uint16(query[len(query)-4:]) {
+		case TypeA:
+			resp = buildResponse(query, 0, testAnswer{TypeA, 300, []byte{203, 0, 113, 10}})
+		default:
+			resp = buildResponse(query, 0, testAnswer{TypeAAAA, 60, netip.MustParseAddr("2001:db8::10").AsSlice()})
+		}
+		frame := binary.BigEndian.AppendUint16(nil, uint16(len(resp)))
+		if _, err := conn.Write(append(frame, resp...)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *dotServer) resolver(t *testing.T) *DoTResolver {
+	t.Helper()
+	r, err := NewDoTResolver(s.addr, "dns.example", 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.TLSConfig.RootCAs = s.pool
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func (s *dotServer) counts() (conns, queries int32) {
+	return atomic.LoadInt32(&s.conns), atomic.LoadInt32(&s.queries)
+}
+
+// TestDoTReusesAndReconnects 两次查询复用同一个连接，服务器关闭连接后的查询重新连接
+func TestDoTReusesAndReconnects(t *testing.T) {
+	srv := startDoTServer(t, 2)
+	r := srv.resolver(t)
+	ctx := context.Background()
+
+	query := func(n int, qtype uint16) {
+		t.Helper()
+		id := uint16(n)
+		q, err := BuildQuery(id, "planet.example.com", qtype)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := r.exchange(ctx, q)
+		if err != nil {
+			t.Fatalf("第%d次查询: %v", n, err)
+		}
+		if ips, _, err := ParseResponse(resp, id); err != nil || len(ips) != 1 {
+			t.Fatalf("第%d次查询结果为 %v %v", n, ips, err)
+		}
+	}
+
+	query(1, TypeA)
+	query(2, TypeAAAA)
+	if conns, queries := srv.counts(); conns != 1 || queries != 2 {
+		t.Fatalf("两次查询使用 %d 个连接、应答 %d 次，期望 1 个连接", conns, queries)
+	}
+
+	// 服务器已关闭第一个连接，第三次查询在复用的连接上失败后重新连接并重试
+	query(3, TypeA)
+	if conns, queries := srv.counts(); conns != 2 || queries != 3 {
+		t.Errorf("第三次查询后共 %d 个连接、应答 %d 次，期望 2 个连接 3 次", conns, queries)
+	}
+
+	// 客户端主动关闭后同样重新连接
+	r.Close()
+	query(4, TypeAAAA)
+	if conns, _ := srv.counts(); conns != 3 {
+		t.Errorf("关闭后共 %d 个连接，期望 3 个", conns)
+	}
+}
+
+func TestDoTLookup(t *testing.T) {
+	srv := startDoTServer(t, 100)
+	r := srv.resolver(t)
+	for i := 0; i < 3; i++ {
+		result, err := r.Lookup(context.Background(), "planet.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := answerKey(result.IPs); got != "203.0.113.10,2001:db8::10" || result.TTL != time.Minute {
+			t.Errorf("第%d次解析结果为 [%s] TTL %v", i+1, got, result.TTL)
+		}
+	}
+	if conns, queries := srv.counts(); conns != 1 || queries != 6 {
+		t.Errorf("三次解析使用 %d 个连接、应答 %d 次", conns, queries)
+	}
+
+	// 证书主机名不匹配时拒绝连接
+	bad, err := NewDoTResolver(srv.addr, "other.example", 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad.TLSConfig.RootCAs = srv.pool
+	if _, err := bad.Lookup(context.Background(), "planet.example.com"); err == nil {
+		t.Error("证书主机名不匹配时未返回错误")
+	}
+}
@@ -0,0 +1,144 @@
+package resolver
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// DNS 记录类型
+const (
+	TypeA    uint16 = 1
+	TypeAAAA uint16 = 28
+
+	classIN     = 1
+	headerLen   = 12
+	flagRD      = 0x0100
+	flagQR      = 0x8000
+	flagTC      = 0x0200
+	rcodeNXName = 3
+)
+
+// errTruncated UDP 响应被截断，需要改用 TCP 重试
+var errTruncated = errors.New("DNS响应被截断")
+
+// newID 生成随机查询 ID
+func newID() uint16 {
+	var b [2]byte
+	rand.Read(b[:])
+	return binary.BigEndian.Uint16(b[:])
+}
+
+// BuildQuery 构造单个问题的 DNS 查询报文（期望递归）
+func BuildQuery(id uint16, name string, qtype uint16) ([]byte, error) {
+	msg := make([]byte, headerLen, 512)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], flagRD)
+	binary.BigEndian.PutUint16(msg[4:], 1) // QDCOUNT
+
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return nil, fmt.Errorf("无效的域名: %q", name)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("无效的域名: %q", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, classIN)
+	return msg, nil
+}
+
+// skipName 跳过报文中的域名（支持压缩指针），返回其后的偏移
+func skipName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, errors.New("DNS报文域名越界")
+		}
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			return off + 1, nil
+		case l&0xC0 == 0xC0:
+			if off+2 > len(msg) {
+				return 0, errors.New("DNS报文压缩指针越界")
+			}
+			return off + 2, nil
+		case l&0xC0 != 0:
+			return 0, fmt.Errorf("不支持的DNS标签类型: %#x", l)
+		default:
+			off += 1 + l
+		}
+	}
+}
+
+// ParseResponse 解析 DNS 响应，返回 A/AAAA 记录及其中最小的 TTL（秒）
+func ParseResponse(msg []byte, id uint16) ([]netip.Addr, uint32, error) {
+	if len(msg) < headerLen {
+		return nil, 0, errors.New("DNS响应过短")
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return nil, 0, errors.New("DNS响应ID不匹配")
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&flagQR == 0 {
+		return nil, 0, errors.New("收到的不是DNS响应")
+	}
+	if flags&flagTC != 0 {
+		return nil, 0, errTruncated
+	}
+	switch rcode := flags & 0x000F; rcode {
+	case 0:
+	case rcodeNXName:
+		return nil, 0, errors.New("域名不存在")
+	default:
+		return nil, 0, fmt.Errorf("DNS服务器返回错误码: %d", rcode)
+	}
+
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+	off := headerLen
+	var err error
+	for i := 0; i < qdcount; i++ {
+		if off, err = skipName(msg, off); err != nil {
+			return nil, 0, err
+		}
+		off += 4
+	}
+
+	var ips []netip.Addr
+	var minTTL uint32
+	for i := 0; i < ancount; i++ {
+		if off, err = skipName(msg, off); err != nil {
+			return nil, 0, err
+		}
+		if off+10 > len(msg) {
+			return nil, 0, errors.New("DNS应答记录越界")
+		}
+		rtype := binary.BigEndian.Uint16(msg[off:])
+		ttl := binary.BigEndian.Uint32(msg[off+4:])
+		rdlen := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+rdlen > len(msg) {
+			return nil, 0, errors.New("DNS应答数据越界")
+		}
+		rdata := msg[off : off+rdlen]
+		off += rdlen
+
+		if (rtype == TypeA && rdlen == 4) || (rtype == TypeAAAA && rdlen == 16) {
+			ip, _ := netip.AddrFromSlice(rdata)
+			ips = append(ips, ip)
+			if len(ips) == 1 || ttl < minTTL {
+				minTTL = ttl
+			}
+		}
+	}
+	return ips, minTTL, nil
+}
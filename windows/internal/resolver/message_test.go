@@ -0,0 +1,231 @@
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// testAnswer 测试响应中的一条应答记录
+type testAnswer struct {
+	rtype uint16
+	ttl   uint32
+	data  []byte
+}
+
+// buildResponse 根据查询构造响应，应答记录的名称使用指向问题名称的压缩指针
+func buildResponse(query []byte, flags uint16, answers ...testAnswer) []byte {
+	msg := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(msg[2:], flagQR|flagRD|flags)
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(answers)))
+	for _, a := range answers {
+		msg = append(msg, 0xC0, headerLen)
+		msg = binary.BigEndian.AppendUint16(msg, a.rtype)
+		msg = binary.BigEndian.AppendUint16(msg, classIN)
+		msg = binary.BigEndian.AppendUint32(msg, a.ttl)
+		msg = binary.BigEndian.AppendUint16(msg, uint16(len(a.data)))
+		msg = append(msg, a.data...)
+	}
+	return msg
+}
+
+func mustQuery(t *testing.T, id uint16, qtype uint16) []byte {
+	t.Helper()
+	query, err := BuildQuery(id, "zt.example.com.", qtype)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return query
+}
+
+func TestBuildQuery(t *testing.T) {
+	query := mustQuery(t, 0x1234, TypeAAAA)
+	want := []byte{
+		0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0,
+		2, 'z', 't', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		0, 28, 0, 1,
+	}
+	if string(query) != string(want) {
+		t.Errorf("查询报文为 %x，期望 %x", query, want)
+	}
+	for _, name := range []string{"", ".", "a..b", string(make([]byte, 64)) + ".com"} {
+		if _, err := BuildQuery(1, name, TypeA); err == nil {
+			t.Errorf("无效域名 %q 未返回错误", name)
+		}
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	query := mustQuery(t, 7, TypeA)
+	resp := buildResponse(query, 0,
+		testAnswer{TypeA, 300, []byte{203, 0, 113, 10}},
+		testAnswer{5, 30, []byte{0xC0, headerLen}}, // CNAME 记录被忽略，也不参与 TTL
+		testAnswer{TypeA, 120, []byte{198, 51, 100, 7}},
+	)
+	ips, ttl, err := ParseResponse(resp, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []netip.Addr{netip.MustParseAddr("203.0.113.10"), netip.MustParseAddr("198.51.100.7")}
+	if len(ips) != 2 || ips[0] != want[0] || ips[1] != want[1] {
+		t.Errorf("解析结果为 %v，期望 %v", ips, want)
+	}
+	if ttl != 120 {
+		t.Errorf("TTL 为 %d，期望 120", ttl)
+	}
+}
+
+func TestParseResponseErrors(t *testing.T) {
+	query := mustQuery(t, 7, TypeA)
+	ok := buildResponse(query, 0, testAnswer{TypeA, 60, []byte{203, 0, 113, 10}})
+
+	badLabel := append([]byte(nil), ok...)
+	badLabel[len(query)] = 0x80 // 保留的标签类型
+
+	tests := []struct {
+		name string
+		msg  []byte
+		id   uint16
+		want error
+	}{
+		{"ID不匹配", ok, 8, nil},
+		{"不是响应", query, 7, nil},
+		{"截断标志", buildResponse(query, flagTC), 7, errTruncated},
+		{"域名不存在", buildResponse(query, rcodeNXName), 7, nil},
+		{"服务器错误", buildResponse(query, 2), 7, nil},
+		{"保留标签类型", badLabel, 7, nil},
+	}
+	for _, tt := range tests {
+		_, _, err := ParseResponse(tt.msg, tt.id)
+		if err == nil {
+			t.Errorf("%s: 未返回错误", tt.name)
+			continue
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: 错误为 %v，期望 %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestParseResponseCompressionLoop 压缩指针互相指向或指向自身时不能陷入死循环
+func TestParseResponseCompressionLoop(t *testing.T) {
+	query := mustQuery(t, 7, TypeA)
+	msg := buildResponse(query, 0)
+	binary.BigEndian.PutUint16(msg[6:], 2)
+	self := len(msg)
+	msg = append(msg, 0xC0, byte(self)) // 指向自身
+	msg = binary.BigEndian.AppendUint16(msg, TypeA)
+	msg = binary.BigEndian.AppendUint16(msg, classIN)
+	msg = binary.BigEndian.AppendUint32(msg, 60)
+	msg = binary.BigEndian.AppendUint16(msg, 4)
+	msg = append(msg, 203, 0, 113, 10)
+	msg = append(msg, 0xC0, byte(self)) // 指向上一条记录的指针
+	msg = binary.BigEndian.AppendUint16(msg, TypeA)
+	msg = binary.BigEndian.AppendUint16(msg, classIN)
+	msg = binary.BigEndian.AppendUint32(msg, 60)
+	msg = binary.BigEndian.AppendUint16(msg, 4)
+	msg = append(msg, 203, 0, 113, 11)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ips, _, err := ParseResponse(msg, 7)
+		if err != nil || len(ips) != 2 {
+			t.Errorf("解析结果为 %v，错误 %v", ips, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("压缩指针循环导致解析未结束")
+	}
+}
+
+// TestParseResponseTruncated 任意截断位置都应返回错误或只包含完整记录，不能越界
+func TestParseResponseTruncated(t *testing.T) {
+	query := mustQuery(t, 7, TypeAAAA)
+	resp := buildResponse(query, 0,
+		testAnswer{TypeAAAA, 60, netip.MustParseAddr("2001:db8::1").AsSlice()},
+		testAnswer{TypeAAAA, 60, netip.MustParseAddr("2001:db8::2").AsSlice()},
+	)
+	for n := 0; n < len(resp); n++ {
+		ips, _, err := ParseResponse(resp[:n], 7)
+		if err == nil && len(ips) == 2 {
+			t.Errorf("截断为 %d 字节时仍解析出全部记录", n)
+		}
+	}
+	// 逐字节篡改不应导致 panic
+	for i := range resp {
+		for _, b := range []byte{0x00, 0x3F, 0xC0, 0xFF} {
+			msg := append([]byte(nil), resp...)
+			msg[i] = b
+			ParseResponse(msg, 7)
+		}
+	}
+}
+
+// TestDNSResolverTruncatedFallsBackToTCP UDP 响应带截断标志时改用 TCP 查询
+func TestDNSResolverTruncatedFallsBackToTCP(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	udp, err := net.ListenPacket("udp", tcp.Addr().String())
+	if err != nil {
+		t.Skipf("无法在同一端口监听UDP: %v", err)
+	}
+	defer udp.Close()
+
+	answer := func(query []byte) []byte {
+		qtype := binary.BigEndian.Uint16(query[len(query)-4:])
+		if qtype == TypeA {
+			return buildResponse(query, 0, testAnswer{TypeA, 60, []byte{203, 0, 113, 10}})
+		}
+		return buildResponse(query, 0)
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			udp.WriteTo(buildResponse(buf[:n], flagTC), addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			var lenBuf [2]byte
+			if _, err := io.ReadFull(conn, lenBuf[:]); err == nil {
+				query := make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+				if _, err := io.ReadFull(conn, query); err == nil {
+					resp := answer(query)
+					conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+				}
+			}
+			conn.Close()
+		}
+	}()
+
+	r, err := NewDNSResolver("udp", tcp.Addr().String(), 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Lookup(context.Background(), "zt.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.IPs) != 1 || result.IPs[0] != netip.MustParseAddr("203.0.113.10") || result.TTL != time.Minute {
+		t.Errorf("解析结果为 %v TTL %v", result.IPs, result.TTL)
+	}
+}
@@ -0,0 +1,178 @@
+// Package resolver 提供域名 IP 变更检测使用的 DNS 解析器
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// Result 一次解析的结果
+type Result struct {
+	IPs []netip.Addr
+	TTL time.Duration // 记录的最小 TTL，未知时为 0
+}
+
+// Resolver 域名解析器
+type Resolver interface {
+	// Name 返回解析器描述，用于日志
+	Name() string
+	// Lookup 查询域名的全部 A/AAAA 记录
+	Lookup(ctx context.Context, domain string) (*Result, error)
+}
+
+// exchangeFunc 发送一个查询报文并返回响应报文
+type exchangeFunc func(ctx context.Context, query []byte) ([]byte, error)
+
+// lookupWire 通过报文交换函数分别查询 A 和 AAAA 记录并合并结果，任一查询失败即返回错误
+func lookupWire(ctx context.Context, domain string, timeout time.Duration, exchange exchangeFunc) (*Result, error) {
+	result := &Result{}
+	first := true
+	for _, qtype := range []uint16{TypeA, TypeAAAA} {
+		id := newID()
+		query, err := BuildQuery(id, domain, qtype)
+		if err != nil {
+			return nil, err
+		}
+		qctx, cancel := context.WithTimeout(ctx, timeout)
+		resp, err := exchange(qctx, query)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		ips, ttl, err := ParseResponse(resp, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			continue
+		}
+		result.IPs = append(result.IPs, ips...)
+		if first || time.Duration(ttl)*time.Second < result.TTL {
+			result.TTL = time.Duration(ttl) * time.Second
+			first = false
+		}
+	}
+	if len(result.IPs) == 0 {
+		return nil, fmt.Errorf("域名 %s 没有A/AAAA记录", domain)
+	}
+	return result, nil
+}
+
+// SystemResolver 使用操作系统解析器
+type SystemResolver struct {
+	Timeout time.Duration
+}
+
+// Name 返回解析器描述
+func (r *SystemResolver) Name() string {
+	return "system"
+}
+
+// Lookup 通过系统解析器查询，无法获得 TTL
+func (r *SystemResolver) Lookup(ctx context.Context, domain string) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", domain)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{IPs: ips}, nil
+}
+
+// Chain 依次尝试多个解析器，返回第一个成功的结果
+type Chain struct {
+	Resolvers []Resolver
+}
+
+// Name 返回解析器描述
+func (c *Chain) Name() string {
+	names := make([]string, len(c.Resolvers))
+	for i, r := range c.Resolvers {
+		names[i] = r.Name()
+	}
+	return strings.Join(names, " -> ")
+}
+
+// Lookup 依次查询，全部失败时返回合并的错误
+func (c *Chain) Lookup(ctx context.Context, domain string) (*Result, error) {
+	var errs []error
+	for _, r := range c.Resolvers {
+		result, err := r.Lookup(ctx, domain)
+		if err == nil {
+			return result, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", r.Name(), err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// New 根据服务器配置创建解析器：未配置解析服务器时使用系统解析器；
+// 否则 first 模式依次查询配置的服务器，启用 systemResolverFallback 时最后回退到系统解析器，
+// consensus 模式同时查询全部服务器并按法定数量取一致结果，系统解析器只在启用
+// systemResolverFallback 或显式配置 type: system 时参与
+func New(cfg config.ServerConfig) (Resolver, error) {
+	timeout := time.Duration(cfg.ResolverTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	system := &SystemResolver{Timeout: timeout}
+	if len(cfg.Resolvers) == 0 {
+		return system, nil
+	}
+
+	var resolvers []Resolver
+	for i, rc := range cfg.Resolvers {
+		r, err := newResolver(rc, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("解析服务器配置%d无效: %v", i+1, err)
+		}
+		resolvers = append(resolvers, r)
+	}
+	if cfg.SystemResolverFallback {
+		resolvers = append(resolvers, system)
+	}
+
+	switch cfg.ResolverMode {
+	case "", "first":
+		if len(resolvers) == 1 {
+			return resolvers[0], nil
+		}
+		return &Chain{Resolvers: resolvers}, nil
+	case "consensus":
+		return NewConsensus(resolvers, cfg.ResolverQuorum)
+	default:
+		return nil, fmt.Errorf("未知的解析模式: %s", cfg.ResolverMode)
+	}
+}
+
+// newResolver 根据单个解析服务器配置创建解析器
+func newResolver(rc config.ResolverConfig, timeout time.Duration) (Resolver, error) {
+	switch rc.Type {
+	case "", "udp", "tcp":
+		network := rc.Type
+		if network == "" {
+			network = "udp"
+		}
+		return NewDNSResolver(network, rc.Address, timeout)
+	case "doh":
+		return NewDoHResolver(rc.URL, rc.Format, rc.Bootstrap, timeout)
+	case "dot":
+		return NewDoTResolver(rc.Address, rc.ServerName, timeout)
+	case "system":
+		return &SystemResolver{Timeout: timeout}, nil
+	default:
+		return nil, fmt.Errorf("未知的解析服务器类型: %s", rc.Type)
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+	myutiles "github.com/onlypeng/zerotier-extend/windows/internal/utiles"
+)
+
+// changeIsStable 记录候选IP，判断其是否已连续出现足够次数并持续足够时长
+func changeIsStable(agentState *myutiles.AgentState, currentIPs myutiles.IPSet, appConfig config.AppConfig, now time.Time) bool {
+	if appConfig.StableChecks <= 1 && appConfig.StableDuration <= 0 {
+		agentState.Pending = nil
+		return true
+	}
+
+	ips := currentIPs.String()
+	pending := agentState.Pending
+	if pending == nil || pending.IPs != ips {
+		if pending != nil {
+			log.Printf("候选IP由 %s 变为 %s，重新计数", pending.IPs, ips)
+		}
+		pending = &myutiles.PendingChange{IPs: ips, FirstSeen: now}
+		agentState.Pending = pending
+	}
+	pending.Count++
+
+	elapsed := now.Sub(pending.FirstSeen)
+	minDuration := time.Duration(appConfig.StableDuration) * time.Second
+	if pending.Count < appConfig.StableChecks || elapsed < minDuration {
+		log.Printf("候选IP %s 已连续出现%d次、持续%v，需要%d次且%v后才更新",
+			ips, pending.Count, elapsed.Round(time.Second), appConfig.StableChecks, minDuration)
+		return false
+	}
+	log.Printf("候选IP %s 已稳定（%d次，%v）", ips, pending.Count, elapsed.Round(time.Second))
+	return true
+}
+
+// saveCheckState 保存检测时间和候选IP，失败时仅记录日志。
+// 重启记录和熔断状态在变更时已单独写入，这里不覆盖，以免撤销检测期间执行的 reset 命令
+func saveCheckState(path string, agentState *myutiles.AgentState) {
+	err := myutiles.UpdateState(path, func(state *myutiles.AgentState) {
+		state.LastCheck = agentState.LastCheck
+		state.Pending = agentState.Pending
+	})
+	if err != nil {
+		log.Printf("保存运行状态失败: %v\n", err)
+	}
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	myutiles "github.com/onlypeng/zerotier-extend/windows/internal/utiles"
+)
+
+// restartAllowed 判断当前是否允许替换planet并重启服务：在维护窗口内，
+// 或启用了紧急更新且节点已与全部根服务器失联
+func (p *ProgramImpl) restartAllowed(ctx context.Context, now time.Time) bool {
+	if p.maintenance.Allowed(now) {
+		return true
+	}
+	if p.config.AppConfig.Maintenance.Urgent {
+		err := p.zerotierAPI.CheckHealth(ctx)
+		if errors.Is(err, myutiles.ErrRootUnreachable) {
+			log.Printf("当前不在维护窗口内，但%v，立即更新", err)
+			return true
+		}
+		if err != nil {
+			log.Printf("检查根服务器连接失败: %v\n", err)
+		}
+	}
+	if next, ok := p.maintenance.NextWindow(now); ok {
+		log.Printf("当前不在维护窗口内，推迟替换planet和重启服务，下个窗口: %s", next.Format("2006-01-02 15:04 MST"))
+	} else {
+		log.Printf("当前不在维护窗口内，推迟替换planet和重启服务")
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+	myutiles "github.com/onlypeng/zerotier-extend/windows/internal/utiles"
+)
+
+const defaultRestartPeriod = 3600
+
+// restartPermitted 读取状态文件检查重启次数是否超出限制，超限时打开熔断并记录告警。
+// 每次都重新读取并立即写回，使检测期间执行的 reset 命令生效
+func restartPermitted(path string, limit config.RestartLimitConfig, now time.Time) bool {
+	if limit.MaxRestarts <= 0 {
+		return true
+	}
+	permitted := true
+	err := myutiles.UpdateState(path, func(state *myutiles.AgentState) {
+		permitted = checkRestartLimit(state, limit, now)
+	})
+	if err != nil {
+		log.Printf("保存重启状态失败: %v\n", err)
+	}
+	return permitted
+}
+
+// checkRestartLimit 清理过期的重启记录，判断是否允许重启，超限时打开熔断
+func checkRestartLimit(agentState *myutiles.AgentState, limit config.RestartLimitConfig, now time.Time) bool {
+	if c := agentState.Circuit; c != nil {
+		if c.Until.IsZero() || now.Before(c.Until) {
+			log.Printf("告警: 重启已熔断（%s，%s打开），跳过替换planet和重启服务，%s",
+				c.Reason, c.OpenedAt.Format("2006-01-02 15:04:05"), circuitResumeHint(c))
+			return false
+		}
+		log.Printf("熔断冷却结束，恢复重启")
+		agentState.Circuit = nil
+		agentState.Restarts = nil
+	}
+
+	period := time.Duration(limit.Period) * time.Second
+	if period <= 0 {
+		period = defaultRestartPeriod * time.Second
+	}
+	recent := agentState.Restarts[:0]
+	for _, t := range agentState.Restarts {
+		if now.Sub(t) < period {
+			recent = append(recent, t)
+		}
+	}
+	agentState.Restarts = recent
+	if len(recent) < limit.MaxRestarts {
+		return true
+	}
+
+	c := &myutiles.CircuitState{
+		OpenedAt: now,
+		Reason:   fmt.Sprintf("%d秒内已重启%d次", int(period/time.Second), len(recent)),
+	}
+	if limit.Cooldown > 0 {
+		c.Until = now.Add(time.Duration(limit.Cooldown) * time.Second)
+	}
+	agentState.Circuit = c
+	log.Printf("告警: %s，达到上限%d次，打开熔断停止重启ZeroTier，%s", c.Reason, limit.MaxRestarts, circuitResumeHint(c))
+	return false
+}
+
+// recordRestart 在状态文件中记录一次重启
+func recordRestart(path string, limit config.RestartLimitConfig, now time.Time) {
+	if limit.MaxRestarts <= 0 {
+		return
+	}
+	err := myutiles.UpdateState(path, func(state *myutiles.AgentState) {
+		state.Restarts = append(state.Restarts, now)
+	})
+	if err != nil {
+		log.Printf("保存重启记录失败: %v\n", err)
+	}
+}
+
+// circuitResumeHint 返回熔断恢复方式的说明
+func circuitResumeHint(c *myutiles.CircuitState) string {
+	if c.Until.IsZero() {
+		return "需执行 reset 命令恢复"
+	}
+	return fmt.Sprintf("将于%s自动恢复，或执行 reset 命令立即恢复", c.Until.Format("2006-01-02 15:04:05"))
+}
+
+// ResetCircuit 关闭重启熔断并清空重启记录
+func ResetCircuit(cfg *config.Config) error {
+	return myutiles.UpdateState(cfg.AppConfig.StateFilePath, func(state *myutiles.AgentState) {
+		state.Circuit = nil
+		state.Restarts = nil
+	})
+}
+
+// CircuitStatus 返回熔断状态描述，未熔断时返回空字符串
+func CircuitStatus(agentState *myutiles.AgentState) string {
+	c := agentState.Circuit
+	if c == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s（%s打开），%s", c.Reason, c.OpenedAt.Format("2006-01-02 15:04:05"), circuitResumeHint(c))
+}
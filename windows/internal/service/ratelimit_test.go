@@ -0,0 +1,55 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+	myutiles "github.com/onlypeng/zerotier-extend/windows/internal/utiles"
+)
+
+// TestResetDuringCheckIsKept 检测期间执行的 reset 不会被检测结束时保存的状态覆盖
+func TestResetDuringCheckIsKept(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	cfg := &config.Config{}
+	cfg.AppConfig.StateFilePath = path
+	limit := config.RestartLimitConfig{MaxRestarts: 1, Period: 3600}
+	now := time.Now()
+
+	// 检测开始时读取状态
+	agentState, err := myutiles.LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentState.LastCheck = now
+	agentState.Pending = &myutiles.PendingChange{IPs: "203.0.113.10", FirstSeen: now, Count: 1}
+
+	recordRestart(path, limit, now)
+	if restartPermitted(path, limit, now) {
+		t.Fatal("达到重启上限后仍允许重启")
+	}
+	if state, _ := myutiles.LoadState(path); state.Circuit == nil {
+		t.Fatal("熔断状态未写入状态文件")
+	}
+
+	// 检测期间执行 reset，随后检测结束保存状态
+	if err := ResetCircuit(cfg); err != nil {
+		t.Fatal(err)
+	}
+	saveCheckState(path, agentState)
+
+	state, err := myutiles.LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Circuit != nil || len(state.Restarts) != 0 {
+		t.Errorf("reset 被覆盖: 熔断=%v 重启记录=%v", state.Circuit, state.Restarts)
+	}
+	if state.Pending == nil || state.Pending.IPs != "203.0.113.10" || !state.LastCheck.Equal(now) {
+		t.Errorf("检测状态未保存: %+v", state)
+	}
+	if !restartPermitted(path, limit, now) {
+		t.Error("reset 后仍不允许重启")
+	}
+}
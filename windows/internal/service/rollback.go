@@ -0,0 +1,72 @@
+package service
+
+import (
+	"fmt"
+	"log"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+	myutiles "github.com/onlypeng/zerotier-extend/windows/internal/utiles"
+)
+
+// newPlanetHistory 根据配置创建 planet 历史管理器
+func newPlanetHistory(cfg *config.Config) *myutiles.PlanetHistory {
+	appConfig := cfg.AppConfig
+	return myutiles.NewPlanetHistory(appConfig.PlanetHistoryPath, appConfig.PlanetHistoryMaxCount, appConfig.PlanetHistoryMaxDays)
+}
+
+// ListPlanetHistory 返回 planet 历史版本，按时间从新到旧排序
+func ListPlanetHistory(cfg *config.Config) ([]myutiles.PlanetHistoryEntry, error) {
+	return newPlanetHistory(cfg).List()
+}
+
+// RollbackPlanet 重新安装指定的 planet 历史版本（id 为空时为与当前planet不同的最新版本）并重启 ZeroTier，
+// 回滚前当前 planet 会先存入历史，以便再次恢复
+func RollbackPlanet(cfg *config.Config, id string) error {
+	history := newPlanetHistory(cfg)
+	planetPath := cfg.ZeroTierConfig.PlanetPath
+
+	// 先确定目标版本再保存当前planet，否则刚保存的当前版本会成为"最新版本"
+	var target *myutiles.PlanetHistoryEntry
+	var err error
+	if id == "" {
+		target, err = history.Previous(planetPath)
+	} else {
+		target, err = history.Get(id)
+	}
+	if err != nil {
+		return err
+	}
+
+	zerotierService, err := myutiles.NewServiceController(cfg.ZeroTierConfig)
+	if err != nil {
+		return fmt.Errorf("创建服务控制器失败: %v", err)
+	}
+	defer zerotierService.Close()
+
+	localIPs, err := myutiles.GetLocalIPs(cfg.AppConfig.IPFilePath)
+	if err != nil {
+		return fmt.Errorf("获取本地IP失败: %v", err)
+	}
+	current, err := history.Save(planetPath, localIPs)
+	if err != nil {
+		return fmt.Errorf("保存当前planet失败: %v", err)
+	}
+	if current != nil && current.SHA256 == target.SHA256 {
+		return fmt.Errorf("当前planet已是版本 %s", target.ID)
+	}
+	if current != nil {
+		log.Printf("已保存当前planet为历史版本: %s", current.ID)
+	}
+
+	if _, err := history.Restore(target.ID, planetPath); err != nil {
+		return err
+	}
+	log.Printf("已安装planet历史版本: %s（IP: %s）", target.ID, target.IPs)
+
+	log.Printf("等待%v服务重启...", zerotierService.Name())
+	if err := restartService(zerotierService); err != nil {
+		return fmt.Errorf("重启服务失败: %v", err)
+	}
+	log.Printf("重启服务成功")
+	return nil
+}
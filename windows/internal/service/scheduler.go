@@ -0,0 +1,147 @@
+package service
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+// checkOutcome 一次检测的结果
+type checkOutcome int
+
+const (
+	outcomeFailed    checkOutcome = iota // 检测或更新失败
+	outcomeUnchanged                     // IP 未变化
+	outcomeChanged                       // 检测到变更（含等待稳定和已完成更新）
+	outcomeDeferred                      // 检测到变更但不在维护窗口内，推迟更新
+)
+
+// scheduler 计算下次检测前的等待时间
+type scheduler struct {
+	cfg         config.ScheduleConfig
+	base        time.Duration
+	fastUntil   time.Time
+	stableSince time.Time
+	rand        *rand.Rand
+	// nextWindow 返回下个维护窗口的开始时间，推迟更新时用于在窗口开始时立即检测
+	nextWindow func(now time.Time) (time.Time, bool)
+}
+
+// newScheduler 根据配置创建调度器，未配置的间隔使用 checkInterval 推算默认值，
+// nextWindow 为 nil 时不按维护窗口调整
+func newScheduler(appConfig config.AppConfig, nextWindow func(now time.Time) (time.Time, bool)) *scheduler {
+	cfg := appConfig.Schedule
+	base := time.Duration(appConfig.CheckInterval) * time.Second
+	if base <= 0 {
+		base = time.Minute
+	}
+	if cfg.MinInterval <= 0 {
+		cfg.MinInterval = 10
+	}
+	if cfg.MaxInterval <= 0 {
+		cfg.MaxInterval = int(base/time.Second) * 10
+	}
+	if cfg.FastInterval <= 0 {
+		cfg.FastInterval = int(base/time.Second) / 4
+	}
+	if cfg.FastPeriod <= 0 {
+		cfg.FastPeriod = 600
+	}
+	if cfg.BackoffAfter <= 0 {
+		cfg.BackoffAfter = 3600
+	}
+
+	// 以主机名和进程号作为随机种子，使不同主机的抖动互不相同
+	hostname, _ := os.Hostname()
+	h := fnv.New64a()
+	h.Write([]byte(hostname))
+	seed := int64(h.Sum64()) ^ time.Now().UnixNano() ^ int64(os.Getpid())
+
+	return &scheduler{
+		cfg:        cfg,
+		base:       base,
+		rand:       rand.New(rand.NewSource(seed)),
+		nextWindow: nextWindow,
+	}
+}
+
+// next 根据本次检测结果计算下次检测前的等待时间
+func (s *scheduler) next(outcome checkOutcome, ttl time.Duration, now time.Time) time.Duration {
+	if s.cfg.Mode != "adaptive" {
+		return s.untilWindow(s.jitter(s.base), outcome, now)
+	}
+
+	sec := func(n int) time.Duration { return time.Duration(n) * time.Second }
+	switch outcome {
+	case outcomeUnchanged:
+		if s.stableSince.IsZero() {
+			s.stableSince = now
+		}
+	case outcomeDeferred:
+		// 等待维护窗口期间按常规间隔检测，无需加快
+		s.stableSince = time.Time{}
+		s.fastUntil = time.Time{}
+	default:
+		s.stableSince = time.Time{}
+		s.fastUntil = now.Add(sec(s.cfg.FastPeriod))
+	}
+
+	delay := s.base
+	switch {
+	case now.Before(s.fastUntil):
+		delay = sec(s.cfg.FastInterval)
+	default:
+		if s.cfg.UseTTL && ttl > 0 {
+			delay = ttl
+		}
+		// 每稳定一个 BackoffAfter 周期，间隔翻倍
+		if !s.stableSince.IsZero() {
+			for periods := now.Sub(s.stableSince) / sec(s.cfg.BackoffAfter); periods > 0 && delay < sec(s.cfg.MaxInterval); periods-- {
+				delay *= 2
+			}
+		}
+	}
+
+	if delay < sec(s.cfg.MinInterval) {
+		delay = sec(s.cfg.MinInterval)
+	}
+	if delay > sec(s.cfg.MaxInterval) {
+		delay = sec(s.cfg.MaxInterval)
+	}
+	return s.untilWindow(s.jitter(delay), outcome, now)
+}
+
+// untilWindow 推迟更新时，下个维护窗口早于下次检测则改为在窗口开始时检测
+func (s *scheduler) untilWindow(delay time.Duration, outcome checkOutcome, now time.Time) time.Duration {
+	if outcome != outcomeDeferred || s.nextWindow == nil {
+		return delay
+	}
+	start, ok := s.nextWindow(now)
+	if !ok || !start.After(now) {
+		return delay
+	}
+	if wait := start.Sub(now); wait < delay {
+		return wait
+	}
+	return delay
+}
+
+// jitter 按配置的百分比对间隔做随机抖动
+func (s *scheduler) jitter(d time.Duration) time.Duration {
+	if s.cfg.Jitter <= 0 {
+		return d
+	}
+	percent := s.cfg.Jitter
+	if percent > 100 {
+		percent = 100
+	}
+	spread := float64(d) * float64(percent) / 100
+	d += time.Duration((s.rand.Float64()*2 - 1) * spread)
+	if d < time.Second {
+		d = time.Second
+	}
+	return d.Round(time.Second)
+}
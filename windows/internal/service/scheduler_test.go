@@ -0,0 +1,202 @@
+package service
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+// testScheduler 创建使用固定随机种子的调度器
+func testScheduler(checkInterval int, cfg config.ScheduleConfig, nextWindow func(time.Time) (time.Time, bool)) *scheduler {
+	s := newScheduler(config.AppConfig{CheckInterval: checkInterval, Schedule: cfg}, nextWindow)
+	s.rand = rand.New(rand.NewSource(1))
+	return s
+}
+
+func TestSchedulerNext(t *testing.T) {
+	start := time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+	type step struct {
+		after   time.Duration // 相对 start 的时间
+		outcome checkOutcome
+		ttl     time.Duration
+		want    time.Duration
+	}
+	tests := []struct {
+		name     string
+		interval int
+		cfg      config.ScheduleConfig
+		window   time.Duration // 下个维护窗口相对 start 的时间，0 表示没有窗口
+		steps    []step
+	}{
+		{
+			name:     "固定间隔",
+			interval: 60,
+			cfg:      config.ScheduleConfig{Mode: "fixed", UseTTL: true},
+			steps: []step{
+				{0, outcomeUnchanged, 30 * time.Second, time.Minute},
+				{time.Minute, outcomeChanged, 0, time.Minute},
+				{2 * time.Minute, outcomeFailed, 0, time.Minute},
+				{10 * time.Hour, outcomeUnchanged, 0, time.Minute},
+			},
+		},
+		{
+			name:     "变更或失败后快速检测",
+			interval: 60,
+			cfg:      config.ScheduleConfig{Mode: "adaptive"},
+			steps: []step{
+				{0, outcomeUnchanged, 0, time.Minute},
+				{time.Minute, outcomeChanged, 0, 15 * time.Second},
+				{5 * time.Minute, outcomeUnchanged, 0, 15 * time.Second},
+				{11 * time.Minute, outcomeUnchanged, 0, time.Minute}, // 快速期 600 秒已结束
+				{12 * time.Minute, outcomeFailed, 0, 15 * time.Second},
+				{21 * time.Minute, outcomeUnchanged, 0, 15 * time.Second},
+				{23 * time.Minute, outcomeUnchanged, 0, time.Minute},
+			},
+		},
+		{
+			name:     "推迟更新结束快速期",
+			interval: 60,
+			cfg:      config.ScheduleConfig{Mode: "adaptive"},
+			steps: []step{
+				{0, outcomeChanged, 0, 15 * time.Second},
+				{time.Minute, outcomeDeferred, 0, time.Minute},
+				{2 * time.Minute, outcomeUnchanged, 0, time.Minute},
+			},
+		},
+		{
+			name:     "推迟更新时在维护窗口开始时检测",
+			interval: 600,
+			cfg:      config.ScheduleConfig{Mode: "adaptive"},
+			window:   time.Hour,
+			steps: []step{
+				{0, outcomeDeferred, 0, 10 * time.Minute},
+				{55 * time.Minute, outcomeDeferred, 0, 5 * time.Minute},
+				{59*time.Minute + 50*time.Second, outcomeDeferred, 0, 10 * time.Second},
+				{55 * time.Minute, outcomeUnchanged, 0, 10 * time.Minute}, // 未推迟时不受窗口影响
+			},
+		},
+		{
+			name:     "固定间隔推迟更新时在维护窗口开始时检测",
+			interval: 600,
+			cfg:      config.ScheduleConfig{Mode: "fixed"},
+			window:   time.Hour,
+			steps: []step{
+				{55 * time.Minute, outcomeDeferred, 0, 5 * time.Minute},
+				{0, outcomeDeferred, 0, 10 * time.Minute},
+			},
+		},
+		{
+			name:     "TTL作为常规间隔并受最大最小间隔限制",
+			interval: 60,
+			cfg:      config.ScheduleConfig{Mode: "adaptive", UseTTL: true, MaxInterval: 300, MinInterval: 20},
+			steps: []step{
+				{0, outcomeUnchanged, 120 * time.Second, 120 * time.Second},
+				{time.Minute, outcomeUnchanged, time.Hour, 300 * time.Second},
+				{2 * time.Minute, outcomeUnchanged, 5 * time.Second, 20 * time.Second},
+				{3 * time.Minute, outcomeUnchanged, 0, time.Minute}, // 没有 TTL 时使用 checkInterval
+				{4 * time.Minute, outcomeChanged, time.Hour, 20 * time.Second},
+			},
+		},
+		{
+			name:     "未启用TTL时忽略TTL",
+			interval: 60,
+			cfg:      config.ScheduleConfig{Mode: "adaptive"},
+			steps: []step{
+				{0, outcomeUnchanged, 120 * time.Second, time.Minute},
+			},
+		},
+		{
+			name:     "持续稳定时间隔翻倍直到最大间隔",
+			interval: 60,
+			cfg:      config.ScheduleConfig{Mode: "adaptive", BackoffAfter: 3600, MaxInterval: 600},
+			steps: []step{
+				{0, outcomeUnchanged, 0, time.Minute},
+				{59 * time.Minute, outcomeUnchanged, 0, time.Minute},
+				{time.Hour, outcomeUnchanged, 0, 2 * time.Minute},
+				{2 * time.Hour, outcomeUnchanged, 0, 4 * time.Minute},
+				{3 * time.Hour, outcomeUnchanged, 0, 8 * time.Minute},
+				{4 * time.Hour, outcomeUnchanged, 0, 10 * time.Minute},
+				{48 * time.Hour, outcomeUnchanged, 0, 10 * time.Minute},
+				{49 * time.Hour, outcomeChanged, 0, 15 * time.Second}, // 变更后重新计算稳定时间
+				{50 * time.Hour, outcomeUnchanged, 0, time.Minute},
+				{51 * time.Hour, outcomeUnchanged, 0, 2 * time.Minute},
+			},
+		},
+		{
+			name:     "最小间隔",
+			interval: 20,
+			cfg:      config.ScheduleConfig{Mode: "adaptive"},
+			steps: []step{
+				{0, outcomeChanged, 0, 10 * time.Second}, // 默认快速间隔 5 秒被限制为 10 秒
+			},
+		},
+		{
+			name:     "默认值",
+			interval: 0,
+			cfg:      config.ScheduleConfig{Mode: "adaptive", BackoffAfter: 60},
+			steps: []step{
+				{0, outcomeUnchanged, 0, time.Minute},
+				{time.Hour, outcomeUnchanged, 0, 10 * time.Minute}, // 默认最大间隔为 checkInterval 的 10 倍
+			},
+		},
+	}
+	for _, tt := range tests {
+		var nextWindow func(time.Time) (time.Time, bool)
+		if tt.window > 0 {
+			nextWindow = func(now time.Time) (time.Time, bool) {
+				w := start.Add(tt.window)
+				if !now.Before(w) {
+					return now, true
+				}
+				return w, true
+			}
+		}
+		s := testScheduler(tt.interval, tt.cfg, nextWindow)
+		for i, st := range tt.steps {
+			if got := s.next(st.outcome, st.ttl, start.Add(st.after)); got != st.want {
+				t.Errorf("%s: 第%d步等待 %v，期望 %v", tt.name, i+1, got, st.want)
+			}
+		}
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	tests := []struct {
+		jitter   int
+		d        time.Duration
+		min, max time.Duration
+	}{
+		{0, 90 * time.Second, 90 * time.Second, 90 * time.Second},
+		{-5, 90 * time.Second, 90 * time.Second, 90 * time.Second},
+		{10, 100 * time.Second, 90 * time.Second, 110 * time.Second},
+		{50, time.Minute, 30 * time.Second, 90 * time.Second},
+		{300, time.Minute, time.Second, 2 * time.Minute}, // 超过 100 按 100 计算，最少 1 秒
+	}
+	for _, tt := range tests {
+		s := testScheduler(60, config.ScheduleConfig{Jitter: tt.jitter}, nil)
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 1000; i++ {
+			got := s.jitter(tt.d)
+			if got < tt.min || got > tt.max {
+				t.Fatalf("抖动 %d%%: %v 的结果 %v 超出 [%v, %v]", tt.jitter, tt.d, got, tt.min, tt.max)
+			}
+			if got%time.Second != 0 {
+				t.Fatalf("抖动 %d%%: 结果 %v 未取整到秒", tt.jitter, got)
+			}
+			seen[got] = true
+		}
+		if tt.min != tt.max && len(seen) < 2 {
+			t.Errorf("抖动 %d%%: 结果没有变化", tt.jitter)
+		}
+	}
+
+	// 抖动对 next 的结果同样生效
+	s := testScheduler(100, config.ScheduleConfig{Mode: "fixed", Jitter: 20}, nil)
+	for i := 0; i < 100; i++ {
+		if got := s.next(outcomeUnchanged, 0, time.Now()); got < 80*time.Second || got > 120*time.Second {
+			t.Fatalf("固定间隔抖动结果 %v 超出范围", got)
+		}
+	}
+}
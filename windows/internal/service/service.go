@@ -1,58 +1,120 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
 	"time"
 
 	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+	planet "github.com/onlypeng/zerotier-extend/windows/internal/planet"
+	resolver "github.com/onlypeng/zerotier-extend/windows/internal/resolver"
 	myutiles "github.com/onlypeng/zerotier-extend/windows/internal/utiles"
 
 	"github.com/kardianos/service"
 )
 
+// restartMargin 重启等待时间之外为发送命令和查询状态预留的时间
+const restartMargin = 10 * time.Second
+
 type ProgramImpl struct {
-	exit            chan struct{}
+	ctx             context.Context
+	cancel          context.CancelFunc
+	done            chan struct{}
 	config          *config.Config
-	zerotierService *myutiles.WindowsServiceManager
+	zerotierService myutiles.ServiceController
+	zerotierAPI     *myutiles.ZeroTierAPI
+	history         *myutiles.PlanetHistory
+	resolver        resolver.Resolver
+	httpClient      *http.Client
+	httpCache       *myutiles.HTTPCache
+	maintenance     *myutiles.MaintenanceSchedule
 }
 
 // 修改构造函数，注入配置：
 func NewProgram(cfg *config.Config) (*ProgramImpl, error) {
-	zerotierService, err := myutiles.NewWindowsServiceManager(cfg.ZeroTierConfig.ServiceName)
+	zerotierService, err := myutiles.NewServiceController(cfg.ZeroTierConfig)
+	if err != nil {
+		return nil, fmt.Errorf("创建服务控制器失败\n %v", err)
+	}
+	dnsResolver, err := resolver.New(cfg.ServerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("创建DNS解析器失败\n %v", err)
+	}
+	log.Printf("域名解析器: %s", dnsResolver.Name())
+	httpClient, err := myutiles.NewHTTPClient(cfg.HTTPConfig, cfg.ServerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP客户端失败\n %v", err)
+	}
+	maintenance, err := myutiles.NewMaintenanceSchedule(cfg.AppConfig.Maintenance)
 	if err != nil {
-		return nil, fmt.Errorf("创建WindowsServiceManager失败\n %v", err)
+		return nil, fmt.Errorf("解析维护窗口失败\n %v", err)
 	}
+	log.Printf("维护窗口: %s", maintenance)
 	return &ProgramImpl{
-		exit:            make(chan struct{}),
 		config:          cfg,
 		zerotierService: zerotierService,
+		zerotierAPI:     myutiles.NewZeroTierAPI(cfg.ZeroTierConfig.API),
+		history:         newPlanetHistory(cfg),
+		resolver:        dnsResolver,
+		httpClient:      httpClient,
+		httpCache:       myutiles.NewHTTPCache(cfg.AppConfig.HTTPCachePath),
+		maintenance:     maintenance,
 	}, nil
 }
 
 func (p *ProgramImpl) Start(s service.Service) error {
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.done = make(chan struct{})
 	go p.run()
 	return nil
 }
+
+// Stop 取消正在进行的检测，并在 stopTimeout 内等待其结束或完成回滚
 func (p *ProgramImpl) Stop(s service.Service) error {
-	close(p.exit)
+	if p.cancel == nil {
+		return nil
+	}
+	p.cancel()
+	timeout := time.Duration(p.config.AppConfig.StopTimeout) * time.Second
+	if timeout <= 0 {
+		// 最坏情况下正在重启以安装新planet，随后因停止无法验证而回滚并再次重启
+		timeout = 2 * restartTimeout(p.zerotierService)
+	}
+	select {
+	case <-p.done:
+	case <-time.After(timeout):
+		log.Printf("等待检测结束超时(%v)，强制停止", timeout)
+	}
 	return nil
 }
 
-func (p *ProgramImpl) doCheck(config *config.Config) {
+// doCheck 执行一次检测与更新，返回检测结果、DNS记录TTL及服务器要求的重试等待时间，供调度器计算下次检测时间。
+// ctx 取消时放弃尚未开始替换的更新；替换planet后不再响应取消，以免ZeroTier停留在未完成的状态
+func (p *ProgramImpl) doCheck(ctx context.Context, config *config.Config) (outcome checkOutcome, ttl, retryAfter time.Duration) {
 	appConfig := config.AppConfig
 	serverConfig := config.ServerConfig
 	zeroTierConfig := config.ZeroTierConfig
 	checkInterval := appConfig.CheckInterval
 
+	agentState, err := myutiles.LoadState(appConfig.StateFilePath)
+	if err != nil {
+		log.Printf("%v\n", err)
+	}
+	agentState.LastCheck = time.Now()
+	defer saveCheckState(appConfig.StateFilePath, agentState)
+
 	// 1. 检查服务状态
-	statusStr, err := p.zerotierService.Status()
-	if err != nil || statusStr != "正在运行" {
-		log.Printf("服务 %s 未运行，跳过本次检查\n", config.ZeroTierConfig.ServiceName)
+	state, err := p.zerotierService.Status()
+	if err != nil || state != myutiles.StateRunning {
+		log.Printf("服务 %s 未运行，跳过本次检查\n", p.zerotierService.Name())
 		return
 	}
 	// 2. 获取当前IP
-	currentIPs, err := myutiles.GetCurrentIPs(serverConfig.Domain)
+	currentIPs, ttl, err := myutiles.GetCurrentIPs(ctx, p.resolver, serverConfig.Domain)
 	if err != nil {
 		log.Printf("获取当前IP失败: %v\n", err)
 		return
@@ -65,59 +127,206 @@ func (p *ProgramImpl) doCheck(config *config.Config) {
 		return
 	}
 	log.Printf("获取本地IP成功，本地IP: %v", localIPs)
-	if currentIPs == localIPs {
+	if recordedIPs, err := myutiles.ParseIPSet(localIPs); err != nil {
+		log.Printf("本地IP记录无效，按IP已变更处理: %v\n", err)
+	} else if currentIPs.Equal(recordedIPs) {
+		// 与已记录的IP一致时清除候选，避免之后的变更沿用旧候选的计数和开始时间
+		if agentState.Pending != nil {
+			log.Printf("IP与记录一致，放弃候选IP %s", agentState.Pending.IPs)
+			agentState.Pending = nil
+		}
 		log.Printf("IP未变化，跳过更新")
+		outcome = outcomeUnchanged
+		return
+	}
+	if !changeIsStable(agentState, currentIPs, appConfig, time.Now()) {
+		outcome = outcomeChanged
 		return
 	}
 	log.Printf("检测到IP已变更，等待服务器文件更新")
 	// 4. 等待服务器文件更新
-	serverIPs, err := myutiles.WaitForPlanetFileUpdate(serverConfig.IPsURL, appConfig.ServerIPsPath, checkInterval)
+	serverIPs, err := myutiles.WaitForPlanetFileUpdate(ctx, p.httpClient, p.httpCache, serverConfig.IPsURL, appConfig.ServerIPsPath, checkInterval)
 	if err != nil {
 		log.Printf("等待服务器文件更新失败: %v\n", err)
 		return
 	}
 	log.Printf("服务器文件已更新，开始更新planet文件")
 	// 5. 下载并planet文件
-	if err := myutiles.Download(serverConfig.PlanetURL, zeroTierConfig.PlanetPath); err != nil {
+	if err := myutiles.Download(ctx, p.httpClient, p.httpCache, serverConfig.PlanetURL, zeroTierConfig.PlanetPath); err != nil {
 		log.Printf("下载planet文件失败: %v\n", err)
+		var retryErr *myutiles.RetryAfterError
+		if errors.As(err, &retryErr) {
+			retryAfter = retryErr.Delay
+		}
 		return
 	}
 	log.Printf("下载planet文件成功")
-	// 6. 替换planet文件
+	// 5.1 校验planet文件内容
+	tmpPath := zeroTierConfig.PlanetPath + ".tmp"
+	world, err := planet.ReadFile(tmpPath)
+	if err != nil {
+		log.Printf("解析下载的planet文件失败: %v，稍后重试\n", err)
+		os.Remove(tmpPath)
+		return
+	}
+	if err := verifyPlanetIPs(world, currentIPs); err != nil {
+		log.Printf("planet文件与当前IP不一致: %v，稍后重试\n", err)
+		os.Remove(tmpPath)
+		return
+	}
+	rotatedPin, err := verifyPlanetSignature(world, zeroTierConfig.PlanetPin, zeroTierConfig.PlanetPath)
+	if err != nil {
+		log.Printf("planet签名校验失败，拒绝安装: %v\n", err)
+		os.Remove(tmpPath)
+		return
+	}
+	if err := checkPlanetDowngrade(world, zeroTierConfig.PlanetPath, zeroTierConfig.AllowDowngrade); err != nil {
+		log.Printf("%v\n", err)
+		os.Remove(tmpPath)
+		return
+	}
+	log.Printf("planet文件校验通过，时间戳: %d", world.Timestamp)
+	// 5.2 与已安装planet内容相同时无需重启，仅更新IP记录
+	if same, err := sameFileContent(tmpPath, zeroTierConfig.PlanetPath); err != nil {
+		log.Printf("比较planet文件失败: %v\n", err)
+	} else if same {
+		os.Remove(tmpPath)
+		log.Printf("下载的planet与已安装的相同，跳过替换和重启")
+		if err := myutiles.SaveNewIPs(currentIPs.String(), appConfig.IPFilePath, serverIPs, appConfig.ServerIPsPath); err != nil {
+			log.Printf("保存新IP记录失败: %v\n", err)
+			return
+		}
+		agentState.Pending = nil
+		commitPlanetPin(zeroTierConfig.PlanetPin.PinPath, rotatedPin)
+		log.Printf("保存新IP记录成功")
+		outcome = outcomeChanged
+		return
+	}
+	// 5.3 替换和重启会中断网络，仅在维护窗口内执行
+	if !p.restartAllowed(ctx, time.Now()) {
+		os.Remove(tmpPath)
+		outcome = outcomeDeferred
+		return
+	}
+	// 5.4 检查重启次数限制
+	if !restartPermitted(appConfig.StateFilePath, appConfig.RestartLimit, time.Now()) {
+		os.Remove(tmpPath)
+		outcome = outcomeDeferred
+		return
+	}
+	if ctx.Err() != nil {
+		log.Printf("服务正在停止，放弃本次更新")
+		os.Remove(tmpPath)
+		return
+	}
+	// 6. 保存当前planet到历史并替换planet文件
+	entry, err := p.history.Save(zeroTierConfig.PlanetPath, localIPs)
+	if err != nil {
+		log.Printf("保存planet历史版本失败: %v\n", err)
+		return
+	}
+	if entry != nil {
+		log.Printf("已保存planet历史版本: %s", entry.ID)
+	}
 	if err := myutiles.ReplacePlanetFile(zeroTierConfig.PlanetPath); err != nil {
 		log.Printf("替换planet文件失败: %v\n", err)
 		return
 	}
 	log.Printf("替换planet文件成功")
 	// 7. 重启服务
-	log.Printf("等待%v服务重启...", zeroTierConfig.ServiceName)
-	err = p.zerotierService.Restart()
+	log.Printf("等待%v服务重启...", p.zerotierService.Name())
+	err = restartService(p.zerotierService)
+	recordRestart(appConfig.StateFilePath, appConfig.RestartLimit, time.Now())
 	if err != nil {
 		log.Printf("重启服务失败: %v\n", err)
 		return
 	}
 	log.Printf("重启服务成功")
-	// 8. 保存新IP记录
-	if err := myutiles.SaveNewIPs(currentIPs, appConfig.IPFilePath, serverIPs, appConfig.ServerIPsPath); err != nil {
+	// 8. 验证节点是否连接到新planet，失败则回滚
+	if verifyTimeout := zeroTierConfig.API.VerifyTimeout; verifyTimeout > 0 {
+		log.Printf("等待节点上线并连接planet，最长%d秒...", verifyTimeout)
+		// 服务停止时无法确认新planet可用，同样回滚到原planet
+		if err := p.zerotierAPI.WaitForHealthy(ctx, time.Duration(verifyTimeout)*time.Second); err != nil {
+			log.Printf("新planet验证失败: %v\n", err)
+			p.rollbackPlanet(zeroTierConfig.PlanetPath, entry)
+			recordRestart(appConfig.StateFilePath, appConfig.RestartLimit, time.Now())
+			return
+		}
+		log.Printf("节点已上线，planet验证通过")
+	}
+	// 9. 保存新IP记录
+	if err := myutiles.SaveNewIPs(currentIPs.String(), appConfig.IPFilePath, serverIPs, appConfig.ServerIPsPath); err != nil {
 		log.Printf("保存新IP记录失败: %v\n", err)
 		return
 	}
+	// 新IP已生效，候选记录不再需要；新planet已安装，记录轮换后的更新签名公钥
+	agentState.Pending = nil
+	commitPlanetPin(zeroTierConfig.PlanetPin.PinPath, rotatedPin)
 	log.Printf("保存新IP记录成功")
-	log.Printf("更新完成，%d秒后重新开始检测", checkInterval)
+	log.Printf("更新完成")
+	outcome = outcomeChanged
+	return
 }
+
+// rollbackPlanet 恢复替换前保存的planet历史版本（无历史版本时恢复备份文件）并重启服务
+func (p *ProgramImpl) rollbackPlanet(planetPath string, previous *myutiles.PlanetHistoryEntry) {
+	log.Printf("开始回滚planet文件")
+	restored := false
+	if previous != nil {
+		if _, err := p.history.Restore(previous.ID, planetPath); err == nil {
+			log.Printf("已恢复planet历史版本: %s", previous.ID)
+			restored = true
+		} else {
+			log.Printf("恢复planet历史版本失败: %v，尝试恢复备份文件\n", err)
+		}
+	}
+	if !restored {
+		if err := myutiles.RestorePlanetBackup(planetPath); err != nil {
+			log.Printf("回滚planet文件失败: %v\n", err)
+			return
+		}
+	}
+	if err := restartService(p.zerotierService); err != nil {
+		log.Printf("回滚后重启服务失败: %v\n", err)
+		return
+	}
+	log.Printf("已回滚planet文件并重启服务")
+}
+
+// restartTimeout 返回重启 ZeroTier 服务最长的等待时间
+func restartTimeout(ctrl myutiles.ServiceController) time.Duration {
+	return ctrl.RestartTimeout() + restartMargin
+}
+
+// restartService 重启 ZeroTier 服务。替换planet后的重启不响应服务停止，只受重启超时限制，
+// 以免ZeroTier停留在未完成的状态
+func restartService(ctrl myutiles.ServiceController) error {
+	ctx, cancel := context.WithTimeout(context.Background(), restartTimeout(ctrl))
+	defer cancel()
+	return ctrl.Restart(ctx)
+}
+
 func (p *ProgramImpl) run() {
+	defer close(p.done)
 	config := p.config
-	checkInterval := config.AppConfig.CheckInterval
-	ticker := time.NewTicker(time.Duration(checkInterval) * time.Second)
-	defer ticker.Stop()
-	p.doCheck(config)
+	// 清理被中断的下载留下的临时文件
+	defer os.Remove(config.ZeroTierConfig.PlanetPath + ".tmp")
+	sched := newScheduler(config.AppConfig, p.maintenance.NextWindow)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
 	for {
 		select {
-		case <-p.exit:
+		case <-p.ctx.Done():
 			fmt.Println("服务收到退出信号，停止检测循环")
 			return
-		case <-ticker.C:
-			p.doCheck(config)
+		case <-timer.C:
+			outcome, ttl, retryAfter := p.doCheck(p.ctx, config)
+			delay := sched.next(outcome, ttl, time.Now())
+			if delay < retryAfter {
+				delay = retryAfter
+			}
+			log.Printf("%v后进行下次检测", delay)
+			timer.Reset(delay)
 		}
 	}
 }
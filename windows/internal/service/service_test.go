@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+	myutiles "github.com/onlypeng/zerotier-extend/windows/internal/utiles"
+)
+
+// fakeController 记录 Restart 收到的 ctx 截止时间
+type fakeController struct {
+	restartTimeout time.Duration
+	restartErr     error
+	deadline       time.Time
+	hasDeadline    bool
+}
+
+func (f *fakeController) Name() string                           { return "fake" }
+func (f *fakeController) Status() (myutiles.ServiceState, error) { return myutiles.StateRunning, nil }
+func (f *fakeController) Start(ctx context.Context) error        { return nil }
+func (f *fakeController) Stop(ctx context.Context) error         { return nil }
+func (f *fakeController) RestartTimeout() time.Duration          { return f.restartTimeout }
+func (f *fakeController) Close() error                           { return nil }
+func (f *fakeController) Restart(ctx context.Context) error {
+	f.deadline, f.hasDeadline = ctx.Deadline()
+	if f.restartErr != nil {
+		return f.restartErr
+	}
+	return ctx.Err()
+}
+func (f *fakeController) WaitForStatus(ctx context.Context, target myutiles.ServiceState, timeout time.Duration) error {
+	return nil
+}
+
+func TestRestartServiceIsBounded(t *testing.T) {
+	ctrl := &fakeController{restartTimeout: 70 * time.Second}
+	start := time.Now()
+	if err := restartService(ctrl); err != nil {
+		t.Fatal(err)
+	}
+	if !ctrl.hasDeadline {
+		t.Fatal("重启未设置超时")
+	}
+	if got := ctrl.deadline.Sub(start); got < 70*time.Second || got > 70*time.Second+restartMargin+time.Second {
+		t.Errorf("重启超时为 %v，期望 %v", got, 70*time.Second+restartMargin)
+	}
+}
+
+// TestStopWaitsForRestartAndRollback 未配置 stopTimeout 时等待时间足够完成重启和回滚
+func TestStopWaitsForRestartAndRollback(t *testing.T) {
+	ctrl := &fakeController{restartTimeout: 100 * time.Millisecond}
+	p := &ProgramImpl{config: &config.Config{}, zerotierService: ctrl}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.done = make(chan struct{})
+
+	// 模拟检测在服务停止后仍需完成一次重启和一次回滚重启
+	work := 2 * ctrl.restartTimeout
+	go func() {
+		<-p.ctx.Done()
+		time.Sleep(work)
+		close(p.done)
+	}()
+	start := time.Now()
+	if err := p.Stop(nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-p.done:
+	default:
+		t.Errorf("Stop 在 %v 后返回，检测尚未结束", time.Since(start))
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"sort"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+	planet "github.com/onlypeng/zerotier-extend/windows/internal/planet"
+	myutiles "github.com/onlypeng/zerotier-extend/windows/internal/utiles"
+)
+
+// verifyPlanetIPs 检查下载的planet根节点固定地址是否覆盖当前解析结果：
+// 解析到的每个 IPv4 和 IPv6 地址都必须出现在planet中，避免服务器文件只更新了一部分地址
+func verifyPlanetIPs(world *planet.World, currentIPs myutiles.IPSet) error {
+	planetIPs := make(map[netip.Addr]bool)
+	for _, ip := range world.IPs() {
+		planetIPs[ip] = true
+	}
+
+	var missing []string
+	for family, ips := range map[string][]netip.Addr{"IPv4": currentIPs.V4, "IPv6": currentIPs.V6} {
+		var absent []netip.Addr
+		for _, ip := range ips {
+			if !planetIPs[ip] {
+				absent = append(absent, ip)
+			}
+		}
+		if len(absent) > 0 {
+			missing = append(missing, fmt.Sprintf("%s %v", family, absent))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("planet缺少当前IP %v，planet中的地址为 %v", missing, world.IPs())
+	}
+	return nil
+}
+
+// verifyPlanetSignature 校验planet签名。配置了 worldId/updateKey 时以配置为准；否则使用记录在 pinPath 的固定信息，
+// 尚未记录时以已安装的planet为准并立即保存（保存失败视为校验失败），只有未安装planet时才信任下载的planet的自签名。
+// planet轮换了更新签名公钥时返回新的固定信息，由调用方在planet安装成功后保存，
+// 安装前保存会导致推迟或失败的更新再次校验时被拒绝
+func verifyPlanetSignature(world *planet.World, cfg config.PlanetPinConfig, installedPath string) (*planet.Pin, error) {
+	explicit := cfg.UpdateKey != ""
+	var pin *planet.Pin
+	if explicit {
+		pin = &planet.Pin{WorldID: cfg.WorldID, UpdateKey: cfg.UpdateKey}
+	} else {
+		saved, err := planet.LoadPin(cfg.PinPath)
+		if err != nil {
+			return nil, err
+		}
+		pin = saved
+	}
+
+	if pin == nil {
+		installed, err := planet.ReadFile(installedPath)
+		switch {
+		case err == nil:
+			pin = planet.PinOf(installed)
+			log.Printf("以已安装的planet固定World ID %d 及其更新签名公钥", pin.WorldID)
+		case errors.Is(err, os.ErrNotExist):
+			if err := world.Verify(world.UpdateSigningKey); err != nil {
+				return nil, fmt.Errorf("首次安装校验自签名失败: %v", err)
+			}
+			pin = planet.PinOf(world)
+			log.Printf("告警: 未安装planet，信任下载的planet并固定World ID %d，如需防止首次下载被篡改请配置 zerotier.planetPin.worldId 和 updateKey", pin.WorldID)
+		default:
+			return nil, fmt.Errorf("无法从已安装的planet获取固定信息: %v", err)
+		}
+		if err := savePlanetPin(cfg.PinPath, pin); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := world.VerifyPinned(pin); err != nil {
+		if !explicit {
+			return nil, fmt.Errorf("%v（如需安装其他World的planet，请配置 zerotier.planetPin.worldId 和 updateKey）", err)
+		}
+		return nil, err
+	}
+	// 由旧公钥签名的新公钥视为合法轮换
+	next := planet.PinOf(world)
+	if next.UpdateKey == pin.UpdateKey {
+		return nil, nil
+	}
+	if explicit {
+		log.Printf("planet更新签名公钥已轮换为 %s，安装后请同步更新配置 zerotier.planetPin.updateKey", next.UpdateKey)
+		return nil, nil
+	}
+	log.Printf("planet更新签名公钥已轮换为 %s，安装成功后记录", next.UpdateKey)
+	return next, nil
+}
+
+// checkPlanetDowngrade 拒绝时间戳早于已安装planet的同一World，allowDowngrade 为 true 时仅记录日志
+func checkPlanetDowngrade(world *planet.World, installedPath string, allowDowngrade bool) error {
+	if _, err := os.Stat(installedPath); errors.Is(err, os.ErrNotExist) {
+		log.Printf("未安装planet文件，跳过版本比较")
+		return nil
+	}
+	installed, err := planet.ReadFile(installedPath)
+	if err != nil {
+		log.Printf("无法解析已安装的planet文件，跳过版本比较: %v", err)
+		return nil
+	}
+	if installed.ID != world.ID {
+		log.Printf("已安装planet的World ID为 %d，与新planet不同，跳过版本比较", installed.ID)
+		return nil
+	}
+
+	switch {
+	case world.Timestamp > installed.Timestamp:
+		log.Printf("新planet时间戳 %d 晚于已安装的 %d，允许更新", world.Timestamp, installed.Timestamp)
+	case world.Timestamp == installed.Timestamp:
+		log.Printf("新planet时间戳与已安装的相同: %d", world.Timestamp)
+	case allowDowngrade:
+		log.Printf("新planet时间戳 %d 早于已安装的 %d，已配置允许降级，继续更新", world.Timestamp, installed.Timestamp)
+	default:
+		return fmt.Errorf("新planet时间戳 %d (%s) 早于已安装的 %d (%s)，拒绝降级",
+			world.Timestamp, world.Time().Format("2006-01-02 15:04:05"),
+			installed.Timestamp, installed.Time().Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+// sameFileContent 比较两个文件的 SHA256，已安装文件不存在时视为不同
+func sameFileContent(downloadedPath, installedPath string) (bool, error) {
+	if _, err := os.Stat(installedPath); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	downloaded, err := myutiles.FileSHA256(downloadedPath)
+	if err != nil {
+		return false, err
+	}
+	installed, err := myutiles.FileSHA256(installedPath)
+	if err != nil {
+		return false, err
+	}
+	return downloaded == installed, nil
+}
+
+// commitPlanetPin planet安装成功后记录轮换后的固定信息，失败时仅告警：
+// 已安装的planet仍由旧公钥签名，下次检测可再次校验并记录
+func commitPlanetPin(pinPath string, pin *planet.Pin) {
+	if pin == nil {
+		return
+	}
+	if err := savePlanetPin(pinPath, pin); err != nil {
+		log.Printf("告警: 记录轮换后的planet更新签名公钥失败: %v\n", err)
+	}
+}
+
+// savePlanetPin 记录planet固定信息
+func savePlanetPin(pinPath string, pin *planet.Pin) error {
+	if err := planet.SavePin(pinPath, pin); err != nil {
+		return err
+	}
+	log.Printf("已记录planet固定信息: World ID %d", pin.WorldID)
+	return nil
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+	planet "github.com/onlypeng/zerotier-extend/windows/internal/planet"
+	resolver "github.com/onlypeng/zerotier-extend/windows/internal/resolver"
+	myutiles "github.com/onlypeng/zerotier-extend/windows/internal/utiles"
+)
+
+const testWorldID = 1234567890
+
+// testKey 返回由单字节种子生成的 C25519 更新签名公钥，Curve25519 部分用固定值填充
+func testKey(seed byte) []byte {
+	priv := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+	return append(bytes.Repeat([]byte{0x33}, 32), priv.Public().(ed25519.PublicKey)...)
+}
+
+// buildPlanet 构造由 signSeed 签名、携带 updateSeed 公钥的planet，根节点固定地址为 ips
+func buildPlanet(t *testing.T, signSeed, updateSeed byte, timestamp uint64, ips ...string) []byte {
+	t.Helper()
+	var header, body bytes.Buffer
+	header.WriteByte(byte(planet.TypePlanet))
+	binary.Write(&header, binary.BigEndian, uint64(testWorldID))
+	binary.Write(&header, binary.BigEndian, timestamp)
+	header.Write(testKey(updateSeed))
+
+	body.WriteByte(1)
+	body.Write([]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0})
+	body.Write(bytes.Repeat([]byte{0xa1}, planet.PublicKeyLen))
+	body.WriteByte(0)
+	body.WriteByte(byte(len(ips)))
+	for _, s := range ips {
+		ip := netip.MustParseAddr(s)
+		if ip.Is4() {
+			body.WriteByte(4)
+		} else {
+			body.WriteByte(6)
+		}
+		body.Write(ip.AsSlice())
+		binary.Write(&body, binary.BigEndian, uint16(9993))
+	}
+
+	var msg bytes.Buffer
+	msg.Write(bytes.Repeat([]byte{0x7f}, 8))
+	msg.Write(header.Bytes())
+	msg.Write(body.Bytes())
+	msg.Write(bytes.Repeat([]byte{0xf7}, 8))
+	digest := sha512.Sum512(msg.Bytes())
+	priv := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{signSeed}, ed25519.SeedSize))
+	sig := append(ed25519.Sign(priv, digest[:32]), digest[:32]...)
+
+	return append(append(header.Bytes(), sig...), body.Bytes()...)
+}
+
+func parsePlanet(t *testing.T, data []byte) *planet.World {
+	t.Helper()
+	w, err := planet.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func loadPinKey(t *testing.T, path string) string {
+	t.Helper()
+	pin, err := planet.LoadPin(path)
+	if err != nil || pin == nil {
+		t.Fatalf("读取固定信息失败: %v", err)
+	}
+	return pin.UpdateKey
+}
+
+// TestRotationPinnedOnlyAfterInstall 轮换公钥的planet在安装前可重复校验，安装后才记录新公钥
+func TestRotationPinnedOnlyAfterInstall(t *testing.T) {
+	dir := t.TempDir()
+	installed := filepath.Join(dir, "planet")
+	cfg := config.PlanetPinConfig{PinPath: filepath.Join(dir, "planet_pin.json")}
+	if err := os.WriteFile(installed, buildPlanet(t, 1, 1, 1000, "203.0.113.1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	k1 := planet.PinOf(parsePlanet(t, buildPlanet(t, 1, 1, 1000))).UpdateKey
+
+	// 由 K1 签名、轮换到 K2 的planet
+	rotating := parsePlanet(t, buildPlanet(t, 1, 2, 2000, "203.0.113.2"))
+	for i := 0; i < 2; i++ {
+		next, err := verifyPlanetSignature(rotating, cfg, installed)
+		if err != nil {
+			t.Fatalf("第%d次校验失败: %v", i+1, err)
+		}
+		if next == nil || next.UpdateKey != planet.PinOf(rotating).UpdateKey {
+			t.Fatalf("第%d次校验未返回轮换后的公钥", i+1)
+		}
+		if got := loadPinKey(t, cfg.PinPath); got != k1 {
+			t.Fatalf("第%d次校验后固定公钥已变更", i+1)
+		}
+	}
+
+	commitPlanetPin(cfg.PinPath, planet.PinOf(rotating))
+	if got := loadPinKey(t, cfg.PinPath); got != planet.PinOf(rotating).UpdateKey {
+		t.Error("安装后未记录轮换后的公钥")
+	}
+	if _, err := verifyPlanetSignature(parsePlanet(t, buildPlanet(t, 2, 2, 3000)), cfg, installed); err != nil {
+		t.Errorf("由新公钥签名的planet校验失败: %v", err)
+	}
+	if _, err := verifyPlanetSignature(parsePlanet(t, buildPlanet(t, 1, 1, 3000)), cfg, installed); err == nil {
+		t.Error("轮换后仍接受旧公钥签名的planet")
+	}
+}
+
+// staticResolver 返回固定解析结果
+type staticResolver struct{ ips []netip.Addr }
+
+func (r staticResolver) Name() string { return "static" }
+func (r staticResolver) Lookup(ctx context.Context, domain string) (*resolver.Result, error) {
+	return &resolver.Result{IPs: r.ips}, nil
+}
+
+// TestRotationNotPinnedWhenInstallFails 校验通过但重启失败时不记录新公钥，之后仍可安装同一planet
+func TestRotationNotPinnedWhenInstallFails(t *testing.T) {
+	dir := t.TempDir()
+	rotating := buildPlanet(t, 1, 2, 2000, "203.0.113.2")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ips":
+			w.Write([]byte("203.0.113.2,"))
+		case "/planet":
+			w.Write(rotating)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{}
+	cfg.AppConfig.IPFilePath = filepath.Join(dir, "ips.txt")
+	cfg.AppConfig.ServerIPsPath = filepath.Join(dir, "server_ips.txt")
+	cfg.AppConfig.StateFilePath = filepath.Join(dir, "state.json")
+	cfg.AppConfig.CheckInterval = 1
+	cfg.ServerConfig.Domain = "zt.example.com"
+	cfg.ServerConfig.IPsURL = srv.URL + "/ips"
+	cfg.ServerConfig.PlanetURL = srv.URL + "/planet"
+	cfg.ZeroTierConfig.PlanetPath = filepath.Join(dir, "planet")
+	cfg.ZeroTierConfig.PlanetPin.PinPath = filepath.Join(dir, "planet_pin.json")
+	os.WriteFile(cfg.ZeroTierConfig.PlanetPath, buildPlanet(t, 1, 1, 1000, "203.0.113.1"), 0644)
+	os.WriteFile(cfg.AppConfig.IPFilePath, []byte("203.0.113.1"), 0644)
+
+	maintenance, err := myutiles.NewMaintenanceSchedule(cfg.AppConfig.Maintenance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl := &fakeController{restartErr: errors.New("模拟重启失败")}
+	p := &ProgramImpl{
+		config:          cfg,
+		zerotierService: ctrl,
+		history:         myutiles.NewPlanetHistory(filepath.Join(dir, "history"), 0, 0),
+		resolver:        staticResolver{ips: []netip.Addr{netip.MustParseAddr("203.0.113.2")}},
+		httpClient:      http.DefaultClient,
+		httpCache:       myutiles.NewHTTPCache(filepath.Join(dir, "cache")),
+		maintenance:     maintenance,
+	}
+	k1 := planet.PinOf(parsePlanet(t, buildPlanet(t, 1, 1, 1000))).UpdateKey
+	k2 := planet.PinOf(parsePlanet(t, rotating)).UpdateKey
+
+	p.doCheck(context.Background(), cfg)
+	if got := loadPinKey(t, cfg.ZeroTierConfig.PlanetPin.PinPath); got != k1 {
+		t.Fatal("安装失败后固定公钥已变更")
+	}
+
+	// 重启恢复后再次检测，同一planet仍能通过校验，保存IP记录后才记录新公钥
+	ctrl.restartErr = nil
+	p.doCheck(context.Background(), cfg)
+	if got := loadPinKey(t, cfg.ZeroTierConfig.PlanetPin.PinPath); got != k2 {
+		t.Error("安装成功后未记录轮换后的公钥")
+	}
+	if ips, _ := os.ReadFile(cfg.AppConfig.IPFilePath); string(ips) != "203.0.113.2" {
+		t.Errorf("IP记录为 %q", ips)
+	}
+}
+
+func TestVerifyPlanetIPs(t *testing.T) {
+	world := parsePlanet(t, buildPlanet(t, 1, 1, 1000, "203.0.113.1", "203.0.113.2", "2001:db8::1"))
+	tests := []struct {
+		name    string
+		current string
+		ok      bool
+	}{
+		{"全部存在", "203.0.113.1,203.0.113.2,2001:db8::1", true},
+		{"只解析到IPv4", "203.0.113.2", true},
+		{"只解析到IPv6", "2001:db8::1", true},
+		{"planet多出的地址不影响", "203.0.113.1,2001:db8::1", true},
+		{"缺少IPv4", "203.0.113.3,2001:db8::1", false},
+		{"缺少IPv6", "203.0.113.1,2001:db8::2", false},
+		{"IPv4部分匹配", "203.0.113.1,203.0.113.3", false},
+		{"IPv6部分匹配", "2001:db8::1,2001:db8::2", false},
+		{"全部缺少", "198.51.100.1,2001:db8::9", false},
+	}
+	for _, tt := range tests {
+		current, err := myutiles.ParseIPSet(tt.current)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := verifyPlanetIPs(world, current); (err == nil) != tt.ok {
+			t.Errorf("%s: 返回 %v", tt.name, err)
+		}
+	}
+}
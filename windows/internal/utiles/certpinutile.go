@@ -0,0 +1,84 @@
+package utiles
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const pinPrefix = "sha256/"
+
+// SPKIPin 计算证书公钥（SubjectPublicKeyInfo）的 SHA-256 固定值，格式为 sha256/<base64>
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return pinPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// parsePins 校验并规范化固定值，允许省略 sha256/ 前缀
+func parsePins(pins []string) (map[string]bool, error) {
+	set := make(map[string]bool, len(pins))
+	for _, pin := range pins {
+		pin = strings.TrimSpace(pin)
+		value := strings.TrimPrefix(pin, pinPrefix)
+		raw, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("无效的证书公钥固定值: %q（应为 sha256/<base64>）", pin)
+		}
+		set[pinPrefix+value] = true
+	}
+	return set, nil
+}
+
+// pinVerifier 返回只校验服务器证书公钥固定值的 VerifyConnection 函数。
+// 固定值只与服务器证书（叶证书）比较：跳过证书链校验后，链中的其他证书可由任何人附带，不能作为依据
+func pinVerifier(pins map[string]bool) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("服务器未提供证书")
+		}
+		leaf := cs.PeerCertificates[0]
+		if now := time.Now(); now.After(leaf.NotAfter) {
+			return fmt.Errorf("服务器证书已于 %s 过期", leaf.NotAfter.Format("2006-01-02"))
+		}
+		if pin := SPKIPin(leaf); !pins[pin] {
+			return fmt.Errorf("证书公钥固定校验失败: 服务器证书公钥 %s 不在 server.certPins 中", pin)
+		}
+		return nil
+	}
+}
+
+// FetchPeerCertificates 连接 TLS 服务器并返回其证书链（不校验证书），target 可以是 URL 或 host[:port]
+func FetchPeerCertificates(ctx context.Context, target string, timeout time.Duration) ([]*x509.Certificate, error) {
+	host := target
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	if host == "" {
+		return nil, errors.New("未指定服务器地址")
+	}
+	addr := host
+	serverName, _, err := net.SplitHostPort(host)
+	if err != nil {
+		serverName = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		addr = net.JoinHostPort(serverName, "443")
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config:    &tls.Config{ServerName: serverName, InsecureSkipVerify: true},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("连接 %s 失败: %w", addr, err)
+	}
+	defer conn.Close()
+	return conn.(*tls.Conn).ConnectionState().PeerCertificates, nil
+}
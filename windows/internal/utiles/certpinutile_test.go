@@ -0,0 +1,153 @@
+package utiles
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+// pinnedClient 创建固定指定公钥的客户端
+func pinnedClient(t *testing.T, pins ...string) *http.Client {
+	t.Helper()
+	client, err := NewHTTPClient(config.HTTPConfig{Proxy: "direct"}, config.ServerConfig{CertPins: pins})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// selfSignedServer 启动使用新生成的自签名证书的 HTTPS 服务器
+func selfSignedServer(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "planet.example.com"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestParsePins(t *testing.T) {
+	sum := sha256.Sum256([]byte("key"))
+	value := base64.StdEncoding.EncodeToString(sum[:])
+	set, err := parsePins([]string{"sha256/" + value, " " + value + " "})
+	if err != nil || len(set) != 1 || !set["sha256/"+value] {
+		t.Errorf("解析结果为 %v %v", set, err)
+	}
+
+	short := base64.StdEncoding.EncodeToString(sum[:16])
+	for _, pin := range []string{"", "sha256/", "sha256/" + short, "sha1/" + value, "sha256/not-base64!", value[:20]} {
+		if _, err := parsePins([]string{pin}); err == nil {
+			t.Errorf("%q: 未返回错误", pin)
+		}
+	}
+	if _, err := NewHTTPClient(config.HTTPConfig{Proxy: "direct"}, config.ServerConfig{CertPins: []string{"sha256/" + short}}); err == nil {
+		t.Error("无效固定值创建客户端未返回错误")
+	}
+}
+
+func TestCertPinMatch(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	pin := SPKIPin(srv.Certificate())
+
+	// 自签名证书只要公钥匹配即可通过，固定值可省略 sha256/ 前缀
+	for _, pins := range [][]string{{pin}, {strings.TrimPrefix(pin, pinPrefix)}} {
+		if body, err := get(pinnedClient(t, pins...), srv.URL); err != nil || string(body) != "ok" {
+			t.Errorf("固定值 %v: %q %v", pins, body, err)
+		}
+	}
+
+	other := selfSignedServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	otherPin := SPKIPin(other.Certificate())
+	if _, err := get(pinnedClient(t, otherPin), other.URL); err != nil {
+		t.Fatalf("自签名服务器: %v", err)
+	}
+	_, err := get(pinnedClient(t, otherPin), srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "证书公钥固定校验失败") {
+		t.Errorf("公钥不匹配时返回 %v", err)
+	}
+	// 多个固定值中任意一个匹配即可
+	if _, err := get(pinnedClient(t, otherPin, pin), srv.URL); err != nil {
+		t.Errorf("包含匹配的固定值时返回 %v", err)
+	}
+}
+
+func TestCertPinRequiresTLS(t *testing.T) {
+	var plainHits int32
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&plainHits, 1)
+		w.Write([]byte("plain"))
+	}))
+	defer plain.Close()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plain.URL+"/planet", http.StatusFound)
+	}))
+	defer srv.Close()
+	client := pinnedClient(t, SPKIPin(srv.Certificate()))
+
+	if _, err := fetch(context.Background(), client, nil, plain.URL+"/planet?key=secret"); err == nil {
+		t.Error("http:// 请求未被拒绝")
+	} else if strings.Contains(err.Error(), "secret") {
+		t.Errorf("错误信息包含密钥: %v", err)
+	}
+	if _, err := get(client, srv.URL+"/planet"); err == nil {
+		t.Error("重定向到 http:// 未被拒绝")
+	}
+	if n := atomic.LoadInt32(&plainHits); n != 0 {
+		t.Errorf("明文服务器收到 %d 个请求", n)
+	}
+
+	// 未配置固定值时不限制
+	if body, err := get(plain.Client(), plain.URL); err != nil || string(body) != "plain" {
+		t.Errorf("未配置固定值时返回 %q %v", body, err)
+	}
+}
+
+func TestCertPinRejectsHTTPSProxy(t *testing.T) {
+	sum := sha256.Sum256([]byte("key"))
+	pin := pinPrefix + base64.StdEncoding.EncodeToString(sum[:])
+	if _, err := NewHTTPClient(config.HTTPConfig{Proxy: "https://proxy.example.com:8443"}, config.ServerConfig{CertPins: []string{pin}}); err == nil {
+		t.Error("https 代理与证书固定同时使用时未返回错误")
+	}
+	for _, proxy := range []string{"direct", "http://proxy.example.com:8080"} {
+		if _, err := NewHTTPClient(config.HTTPConfig{Proxy: proxy}, config.ServerConfig{CertPins: []string{pin}}); err != nil {
+			t.Errorf("代理 %s: %v", proxy, err)
+		}
+	}
+	if _, err := NewHTTPClient(config.HTTPConfig{Proxy: "https://proxy.example.com:8443"}, config.ServerConfig{}); err != nil {
+		t.Errorf("未配置固定值时 https 代理返回 %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build linux
+
+package utiles
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+// NewServiceController 根据配置创建当前平台的 ZeroTier 服务控制器，auto 时自动检测初始化系统
+func NewServiceController(cfg config.ZeroTierConfig) (ServiceController, error) {
+	controller := cfg.Controller
+	if controller == "" || controller == "auto" {
+		controller = DetectInitSystem()
+		log.Printf("检测到初始化系统: %s", controller)
+	}
+	switch controller {
+	case "systemd":
+		return NewSystemdServiceManager(cfg.ServiceName)
+	case "procd", "openrc", "sysv":
+		return NewInitScriptServiceManager(controller, cfg.ServiceName)
+	case "docker":
+		return NewDockerServiceManager(cfg.Docker)
+	default:
+		return nil, fmt.Errorf("Linux 不支持的服务控制方式: %s", controller)
+	}
+}
+
+// DetectInitSystem 检测当前系统的初始化系统类型：procd、systemd、openrc 或 sysv
+func DetectInitSystem() string {
+	if _, err := os.Stat("/etc/openwrt_release"); err == nil {
+		return "procd"
+	}
+	if data, err := os.ReadFile("/etc/os-release"); err == nil && strings.Contains(strings.ToLower(string(data)), "openwrt") {
+		return "procd"
+	}
+	if _, err := exec.LookPath("systemctl"); err == nil {
+		return "systemd"
+	}
+	if _, err := exec.LookPath("rc-service"); err == nil {
+		return "openrc"
+	}
+	return "sysv"
+}
@@ -0,0 +1,18 @@
+//go:build !windows && !linux
+
+package utiles
+
+import (
+	"fmt"
+	"runtime"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+// NewServiceController 根据配置创建当前平台的 ZeroTier 服务控制器
+func NewServiceController(cfg config.ZeroTierConfig) (ServiceController, error) {
+	if cfg.Controller == "docker" {
+		return NewDockerServiceManager(cfg.Docker)
+	}
+	return nil, fmt.Errorf("不支持的平台: %s", runtime.GOOS)
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package utiles
+
+import (
+	"fmt"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+// NewServiceController 根据配置创建当前平台的 ZeroTier 服务控制器
+func NewServiceController(cfg config.ZeroTierConfig) (ServiceController, error) {
+	switch cfg.Controller {
+	case "", "auto", "windows":
+		return NewWindowsServiceManager(cfg.ServiceName)
+	case "docker":
+		return nil, fmt.Errorf("Windows 不支持 docker 控制方式，Docker Engine API 仅支持通过 unix 套接字访问")
+	default:
+		return nil, fmt.Errorf("Windows 不支持的服务控制方式: %s", cfg.Controller)
+	}
+}
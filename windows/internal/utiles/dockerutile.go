@@ -0,0 +1,220 @@
+//go:build !windows
+
+package utiles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+const defaultDockerSocket = "/var/run/docker.sock"
+
+var _ ServiceController = (*DockerServiceManager)(nil)
+
+// DockerServiceManager 通过 Docker Engine API 控制运行 ZeroTier 的容器
+type DockerServiceManager struct {
+	Container    string
+	Socket       string
+	StopTimeout  int  // 停止容器时等待的秒数，超时后强制结束
+	StartTimeout int  // 等待容器进入运行状态的秒数
+	WaitHealthy  bool // 容器定义了健康检查时，是否等待其变为 healthy
+	client       *http.Client
+}
+
+// dockerContainerState 容器 inspect 结果中的状态部分
+type dockerContainerState struct {
+	State struct {
+		Status     string `json:"Status"`
+		Running    bool   `json:"Running"`
+		Restarting bool   `json:"Restarting"`
+		Health     *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+}
+
+// NewDockerServiceManager 创建 Docker 容器管理器并检查容器是否存在
+func NewDockerServiceManager(cfg config.DockerConfig) (*DockerServiceManager, error) {
+	if cfg.Container == "" {
+		return nil, fmt.Errorf("未配置 Docker 容器名称")
+	}
+	dm := &DockerServiceManager{
+		Container:    cfg.Container,
+		Socket:       cfg.Socket,
+		StopTimeout:  cfg.StopTimeout,
+		StartTimeout: cfg.StartTimeout,
+		WaitHealthy:  cfg.WaitHealthy,
+	}
+	if dm.Socket == "" {
+		dm.Socket = defaultDockerSocket
+	}
+	if dm.StopTimeout <= 0 {
+		dm.StopTimeout = 10
+	}
+	if dm.StartTimeout <= 0 {
+		dm.StartTimeout = 60
+	}
+	dm.client = &http.Client{
+		Timeout: time.Duration(dm.StopTimeout+30) * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", dm.Socket)
+			},
+		},
+	}
+	if _, err := dm.inspect(); err != nil {
+		return nil, err
+	}
+	return dm, nil
+}
+
+// Name 返回容器名称
+func (dm *DockerServiceManager) Name() string {
+	return dm.Container
+}
+
+// do 向 Docker Engine API 发送请求，expected 为视作成功的状态码
+func (dm *DockerServiceManager) do(ctx context.Context, method, path string, query url.Values, expected ...int) ([]byte, error) {
+	u := "http://docker" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	resp, err := dm.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求 Docker API 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取 Docker API 响应失败: %w", err)
+	}
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return body, nil
+		}
+	}
+	var apiErr struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+		return nil, fmt.Errorf("Docker API 返回错误 %d: %s", resp.StatusCode, apiErr.Message)
+	}
+	return nil, fmt.Errorf("Docker API 返回错误状态码: %d", resp.StatusCode)
+}
+
+// inspect 查询容器状态
+func (dm *DockerServiceManager) inspect() (*dockerContainerState, error) {
+	body, err := dm.do(context.Background(), http.MethodGet, "/containers/"+url.PathEscape(dm.Container)+"/json", nil, http.StatusOK)
+	if err != nil {
+		return nil, fmt.Errorf("查询容器 %s 失败: %v", dm.Container, err)
+	}
+	var state dockerContainerState
+	if err := json.Unmarshal(body, &state); err != nil {
+		return nil, fmt.Errorf("解析容器状态失败: %v", err)
+	}
+	return &state, nil
+}
+
+// Status 返回容器当前状态，启用 WaitHealthy 时健康检查未通过视为正在启动
+func (dm *DockerServiceManager) Status() (ServiceState, error) {
+	info, err := dm.inspect()
+	if err != nil {
+		return StateUnknown, err
+	}
+	switch info.State.Status {
+	case "running":
+		if dm.WaitHealthy && info.State.Health != nil && info.State.Health.Status != "healthy" {
+			return StateStartPending, nil
+		}
+		return StateRunning, nil
+	case "restarting":
+		return StateStartPending, nil
+	case "removing":
+		return StateStopPending, nil
+	case "created", "exited", "dead":
+		return StateStopped, nil
+	default:
+		return StateUnknown, nil
+	}
+}
+
+// containerAction 对容器执行 start/stop/restart
+func (dm *DockerServiceManager) containerAction(ctx context.Context, action string, query url.Values) error {
+	path := "/containers/" + url.PathEscape(dm.Container) + "/" + action
+	_, err := dm.do(ctx, http.MethodPost, path, query, http.StatusNoContent, http.StatusNotModified)
+	return err
+}
+
+// stopQuery 返回带停止超时参数的查询串
+func (dm *DockerServiceManager) stopQuery() url.Values {
+	return url.Values{"t": []string{strconv.Itoa(dm.StopTimeout)}}
+}
+
+// Start 启动容器（仅在非运行状态下启动）
+func (dm *DockerServiceManager) Start(ctx context.Context) error {
+	state, err := dm.Status()
+	if err != nil {
+		return err
+	}
+	if state == StateRunning {
+		return fmt.Errorf("容器已在运行，无需重复启动")
+	}
+	if err := dm.containerAction(ctx, "start", nil); err != nil {
+		return fmt.Errorf("启动容器失败: %v", err)
+	}
+	return dm.WaitForStatus(ctx, StateRunning, time.Duration(dm.StartTimeout)*time.Second)
+}
+
+// Stop 停止容器（仅在运行状态下停止）
+func (dm *DockerServiceManager) Stop(ctx context.Context) error {
+	state, err := dm.Status()
+	if err != nil {
+		return err
+	}
+	if state == StateStopped {
+		return fmt.Errorf("容器已停止，无需重复停止")
+	}
+	if err := dm.containerAction(ctx, "stop", dm.stopQuery()); err != nil {
+		return fmt.Errorf("停止容器失败: %v", err)
+	}
+	return dm.WaitForStatus(ctx, StateStopped, serviceWaitTimeout)
+}
+
+// Restart 重启容器并等待其运行（及健康检查通过）
+func (dm *DockerServiceManager) Restart(ctx context.Context) error {
+	if err := dm.containerAction(ctx, "restart", dm.stopQuery()); err != nil {
+		return fmt.Errorf("重启容器失败: %v", err)
+	}
+	return dm.WaitForStatus(ctx, StateRunning, time.Duration(dm.StartTimeout)*time.Second)
+}
+
+// RestartTimeout 容器停止超时与启动超时之和
+func (dm *DockerServiceManager) RestartTimeout() time.Duration {
+	return time.Duration(dm.StopTimeout+dm.StartTimeout) * time.Second
+}
+
+// WaitForStatus 等待容器达到指定状态
+func (dm *DockerServiceManager) WaitForStatus(ctx context.Context, target ServiceState, timeout time.Duration) error {
+	return waitForState(ctx, dm, target, timeout)
+}
+
+// Close 关闭空闲连接
+func (dm *DockerServiceManager) Close() error {
+	dm.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,148 @@
+//go:build !windows
+
+package utiles
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+// fakeDocker 模拟 Docker Engine API 的容器 inspect 和 restart 接口，
+// states 为重启后依次返回的状态（最后一个保持不变），每项为 "status" 或 "status/health"
+type fakeDocker struct {
+	mu       sync.Mutex
+	current  string
+	states   []string
+	restarts []string // 每次 restart 请求的 t 参数
+}
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/containers/zt/json":
+		if len(f.states) > 0 {
+			f.current, f.states = f.states[0], f.states[1:]
+		}
+		status, health, _ := strings.Cut(f.current, "/")
+		state := map[string]interface{}{"Status": status, "Running": status == "running"}
+		if health != "" {
+			state["Health"] = map[string]string{"Status": health}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"State": state})
+	case r.Method == http.MethodPost && r.URL.Path == "/containers/zt/restart":
+		f.restarts = append(f.restarts, r.URL.Query().Get("t"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "No such container"})
+	}
+}
+
+// setState 设置当前状态及重启后依次返回的状态
+func (f *fakeDocker) setState(current string, next ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.current, f.states = current, next
+}
+
+// startFakeDocker 在临时 unix 套接字上启动模拟服务
+func startFakeDocker(t *testing.T, fake *fakeDocker) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("无法监听 unix 套接字: %v", err)
+	}
+	srv := &http.Server{Handler: fake}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return socket
+}
+
+func TestDockerStatus(t *testing.T) {
+	fake := &fakeDocker{current: "running"}
+	socket := startFakeDocker(t, fake)
+	dm, err := NewDockerServiceManager(config.DockerConfig{Socket: socket, Container: "zt", WaitHealthy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dm.Close()
+
+	tests := []struct {
+		state string
+		want  ServiceState
+	}{
+		{"running", StateRunning},
+		{"running/healthy", StateRunning},
+		{"running/starting", StateStartPending},
+		{"restarting", StateStartPending},
+		{"removing", StateStopPending},
+		{"exited", StateStopped},
+		{"paused", StateUnknown},
+	}
+	for _, tt := range tests {
+		fake.setState(tt.state)
+		got, err := dm.Status()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.state, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: 状态为 %v，期望 %v", tt.state, got, tt.want)
+		}
+	}
+}
+
+func TestDockerRestartWaitsForHealthy(t *testing.T) {
+	fake := &fakeDocker{current: "running/healthy"}
+	socket := startFakeDocker(t, fake)
+	dm, err := NewDockerServiceManager(config.DockerConfig{Socket: socket, Container: "zt", StopTimeout: 7, StartTimeout: 5, WaitHealthy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dm.Close()
+
+	fake.setState("running/healthy", "restarting", "running/starting", "running/healthy")
+	if err := dm.Restart(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.restarts) != 1 || fake.restarts[0] != "7" {
+		t.Errorf("restart 请求为 %v，期望一次且 t=7", fake.restarts)
+	}
+	if len(fake.states) != 0 {
+		t.Errorf("未等待容器变为 healthy，剩余状态 %v", fake.states)
+	}
+}
+
+func TestDockerRestartTimeout(t *testing.T) {
+	fake := &fakeDocker{current: "running/healthy"}
+	socket := startFakeDocker(t, fake)
+	dm, err := NewDockerServiceManager(config.DockerConfig{Socket: socket, Container: "zt", StartTimeout: 1, WaitHealthy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dm.Close()
+
+	fake.setState("running/healthy", "running/unhealthy")
+	if err := dm.Restart(context.Background()); err == nil {
+		t.Fatal("健康检查未通过时应超时失败")
+	}
+}
+
+func TestDockerMissingContainer(t *testing.T) {
+	socket := startFakeDocker(t, &fakeDocker{})
+	_, err := NewDockerServiceManager(config.DockerConfig{Socket: socket, Container: "missing"})
+	if err == nil || !strings.Contains(err.Error(), "No such container") {
+		t.Fatalf("错误为 %v，期望包含 Docker API 的错误信息", err)
+	}
+}
@@ -0,0 +1,221 @@
+package utiles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const historyIndexFile = "history.json"
+
+// PlanetHistoryEntry 一个被替换下来的 planet 版本
+type PlanetHistoryEntry struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	SHA256 string    `json:"sha256"`
+	IPs    string    `json:"ips"` // 该 planet 对应的域名 IP
+	File   string    `json:"file"`
+}
+
+// PlanetHistory 管理 planet 历史版本目录
+type PlanetHistory struct {
+	Dir      string
+	MaxCount int
+	MaxAge   time.Duration
+}
+
+// NewPlanetHistory 创建 planet 历史管理器，maxCount、maxDays 小于等于 0 表示不限制
+func NewPlanetHistory(dir string, maxCount, maxDays int) *PlanetHistory {
+	return &PlanetHistory{
+		Dir:      dir,
+		MaxCount: maxCount,
+		MaxAge:   time.Duration(maxDays) * 24 * time.Hour,
+	}
+}
+
+// FileSHA256 计算文件的 SHA256 摘要
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("读取文件失败: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// List 返回所有历史版本，按时间从新到旧排序
+func (h *PlanetHistory) List() ([]PlanetHistoryEntry, error) {
+	data, err := os.ReadFile(filepath.Join(h.Dir, historyIndexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取历史索引失败: %w", err)
+	}
+	var entries []PlanetHistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析历史索引失败: %w", err)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+	return entries, nil
+}
+
+// writeIndex 原子写入历史索引
+func (h *PlanetHistory) writeIndex(entries []PlanetHistoryEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化历史索引失败: %w", err)
+	}
+	indexPath := filepath.Join(h.Dir, historyIndexFile)
+	if err := os.WriteFile(indexPath+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("写入历史索引失败: %w", err)
+	}
+	if err := os.Rename(indexPath+".tmp", indexPath); err != nil {
+		return fmt.Errorf("替换历史索引失败: %w", err)
+	}
+	return nil
+}
+
+// Save 将当前安装的 planet 存入历史。内容与已有版本相同时不重复保存文件，
+// 而是更新该版本的时间和IP并移到最前，使其成为最近被替换的版本，返回该版本
+func (h *PlanetHistory) Save(planetPath, ips string) (*PlanetHistoryEntry, error) {
+	if _, err := os.Stat(planetPath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	sum, err := FileSHA256(planetPath)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := h.List()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range entries {
+		if entries[i].SHA256 != sum {
+			continue
+		}
+		entry := entries[i]
+		entry.Time = now
+		entry.IPs = ips
+		rest := append(entries[:i:i], entries[i+1:]...)
+		if err := h.writeIndex(h.prune(append([]PlanetHistoryEntry{entry}, rest...), now)); err != nil {
+			return nil, err
+		}
+		return &entry, nil
+	}
+	if err := os.MkdirAll(h.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建历史目录失败: %w", err)
+	}
+
+	entry := PlanetHistoryEntry{
+		ID:     now.Format("20060102150405"),
+		Time:   now,
+		SHA256: sum,
+		IPs:    ips,
+	}
+	for _, e := range entries {
+		if e.ID == entry.ID {
+			entry.ID += "-" + sum[:8]
+			break
+		}
+	}
+	entry.File = entry.ID + ".planet"
+	if err := CopyFile(planetPath, filepath.Join(h.Dir, entry.File)); err != nil {
+		return nil, fmt.Errorf("保存历史planet失败: %w", err)
+	}
+
+	entries = append([]PlanetHistoryEntry{entry}, entries...)
+	if err := h.writeIndex(h.prune(entries, now)); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// prune 按数量和时间清理历史版本，返回保留的条目
+func (h *PlanetHistory) prune(entries []PlanetHistoryEntry, now time.Time) []PlanetHistoryEntry {
+	kept := entries[:0:0]
+	for i, e := range entries {
+		expired := h.MaxAge > 0 && now.Sub(e.Time) > h.MaxAge
+		overflow := h.MaxCount > 0 && i >= h.MaxCount
+		// 始终保留最近的两个版本，保证回滚前保存当前版本后仍可恢复上一版本
+		if i > 1 && (expired || overflow) {
+			os.Remove(filepath.Join(h.Dir, e.File))
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// Get 查找指定版本
+func (h *PlanetHistory) Get(id string) (*PlanetHistoryEntry, error) {
+	entries, err := h.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("没有可用的planet历史版本")
+	}
+	for i := range entries {
+		if entries[i].ID == id {
+			return &entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("planet历史版本 %s 不存在", id)
+}
+
+// Previous 返回内容与 planetPath 当前安装的 planet 不同的最新版本，即回滚的默认目标
+func (h *PlanetHistory) Previous(planetPath string) (*PlanetHistoryEntry, error) {
+	entries, err := h.List()
+	if err != nil {
+		return nil, err
+	}
+	installed, err := FileSHA256(planetPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].SHA256 != installed {
+			return &entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("没有与当前planet不同的历史版本")
+}
+
+// Restore 将指定历史版本安装到 planetPath，并校验文件摘要
+func (h *PlanetHistory) Restore(id, planetPath string) (*PlanetHistoryEntry, error) {
+	entry, err := h.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	src := filepath.Join(h.Dir, entry.File)
+	sum, err := FileSHA256(src)
+	if err != nil {
+		return nil, err
+	}
+	if sum != entry.SHA256 {
+		return nil, fmt.Errorf("planet历史版本 %s 摘要不匹配，文件可能已损坏", entry.ID)
+	}
+	if err := CopyFile(src, planetPath+".tmp"); err != nil {
+		return nil, fmt.Errorf("复制历史planet失败: %w", err)
+	}
+	if err := os.Rename(planetPath+".tmp", planetPath); err != nil {
+		return nil, fmt.Errorf("替换文件失败: %w", err)
+	}
+	return entry, nil
+}
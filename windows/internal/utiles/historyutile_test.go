@@ -0,0 +1,114 @@
+package utiles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// installPlanet 写入模拟的已安装 planet 并存入历史
+func installPlanet(t *testing.T, h *PlanetHistory, planetPath, content string) *PlanetHistoryEntry {
+	t.Helper()
+	if err := os.WriteFile(planetPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := h.Save(planetPath, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestPlanetHistorySaveDedupesAllEntries(t *testing.T) {
+	dir := t.TempDir()
+	h := NewPlanetHistory(filepath.Join(dir, "history"), 0, 0)
+	planetPath := filepath.Join(dir, "planet")
+
+	a := installPlanet(t, h, planetPath, "A")
+	installPlanet(t, h, planetPath, "B")
+	again := installPlanet(t, h, planetPath, "A")
+	if again.ID != a.ID {
+		t.Errorf("重复内容保存为新版本 %s，期望复用 %s", again.ID, a.ID)
+	}
+	entries, err := h.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("历史版本数为 %d，期望 2", len(entries))
+	}
+}
+
+func TestPlanetHistoryPreviousSkipsInstalled(t *testing.T) {
+	dir := t.TempDir()
+	h := NewPlanetHistory(filepath.Join(dir, "history"), 0, 0)
+	planetPath := filepath.Join(dir, "planet")
+
+	a := installPlanet(t, h, planetPath, "A")
+	b := installPlanet(t, h, planetPath, "B")
+
+	// 当前安装 B，默认回滚目标为 A
+	prev, err := h.Previous(planetPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prev.ID != a.ID {
+		t.Fatalf("回滚目标为 %s，期望 %s", prev.ID, a.ID)
+	}
+	if _, err := h.Restore(prev.ID, planetPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// 回滚到 A 后再次回滚应回到 B，而不是停留在 A
+	prev, err = h.Previous(planetPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prev.ID != b.ID {
+		t.Errorf("再次回滚目标为 %s，期望 %s", prev.ID, b.ID)
+	}
+}
+
+func TestPlanetHistoryPreviousWithoutOtherVersion(t *testing.T) {
+	dir := t.TempDir()
+	h := NewPlanetHistory(filepath.Join(dir, "history"), 0, 0)
+	planetPath := filepath.Join(dir, "planet")
+
+	installPlanet(t, h, planetPath, "A")
+	if _, err := h.Previous(planetPath); err == nil {
+		t.Error("只有当前版本时应返回错误")
+	}
+}
+
+// TestPlanetHistoryReinstalledVersionIsNewest 重新安装过的版本再次被替换时成为最新版本
+func TestPlanetHistoryReinstalledVersionIsNewest(t *testing.T) {
+	dir := t.TempDir()
+	h := NewPlanetHistory(filepath.Join(dir, "history"), 2, 0)
+	planetPath := filepath.Join(dir, "planet")
+
+	// 依次安装 A、B、A、C，每次安装前保存当前planet
+	a := installPlanet(t, h, planetPath, "A")
+	installPlanet(t, h, planetPath, "B")
+	again := installPlanet(t, h, planetPath, "A")
+	if err := os.WriteFile(planetPath, []byte("C"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != a.ID || again.IPs != "A" {
+		t.Errorf("重复内容返回 %+v，期望复用 %s", again, a.ID)
+	}
+
+	prev, err := h.Previous(planetPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prev.ID != a.ID {
+		t.Errorf("回滚目标为 %s，期望最近被替换的 %s", prev.ID, a.ID)
+	}
+	entries, err := h.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != a.ID || !entries[0].Time.After(entries[1].Time) {
+		t.Errorf("历史版本为 %+v，期望 A 在最前", entries)
+	}
+}
@@ -0,0 +1,196 @@
+package utiles
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	maxResponseSize = 1 << 20   // 服务器 ips/planet 响应的最大长度
+	maxRetryAfter   = time.Hour // Retry-After 的最长等待时间
+)
+
+// HTTPCacheEntry 缓存的服务器响应
+type HTTPCacheEntry struct {
+	URL          string    `json:"url"` // 已去掉查询参数
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"` // 获取到响应内容的时间
+	CheckedAt    time.Time `json:"checkedAt"` // 最近一次确认内容未变化的时间
+	Body         []byte    `json:"body"`
+}
+
+// HTTPCache 将服务器的最近一次成功响应保存到磁盘，用于条件请求和服务器不可用时的诊断
+type HTTPCache struct {
+	Dir string
+}
+
+// NewHTTPCache 创建响应缓存，dir 为空时返回 nil（不缓存）
+func NewHTTPCache(dir string) *HTTPCache {
+	if dir == "" {
+		return nil
+	}
+	return &HTTPCache{Dir: dir}
+}
+
+// path 返回 URL 对应的缓存文件路径，文件名为 URL 的摘要，避免密钥出现在文件名中
+func (c *HTTPCache) path(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// Get 读取 URL 的缓存，不存在时返回 nil
+func (c *HTTPCache) Get(rawURL string) (*HTTPCacheEntry, error) {
+	if c == nil {
+		return nil, nil
+	}
+	data, err := os.ReadFile(c.path(rawURL))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取响应缓存失败: %w", err)
+	}
+	var entry HTTPCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("解析响应缓存失败: %w", err)
+	}
+	return &entry, nil
+}
+
+// put 原子写入缓存
+func (c *HTTPCache) put(rawURL string, entry *HTTPCacheEntry) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := c.path(rawURL)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("写入响应缓存失败: %w", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// RetryAfterError 服务器返回 429/503 并要求稍后重试
+type RetryAfterError struct {
+	StatusCode int
+	Delay      time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("服务器繁忙(状态码 %d)，要求%v后重试", e.StatusCode, e.Delay)
+}
+
+// parseRetryAfter 解析秒数或 HTTP 日期格式的 Retry-After
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	var delay time.Duration
+	if secs, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		delay = t.Sub(now)
+	} else {
+		return 0, false
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > maxRetryAfter {
+		delay = maxRetryAfter
+	}
+	return delay, true
+}
+
+// fetch 发送 GET 请求，存在缓存时附带 If-None-Match/If-Modified-Since，
+// 服务器返回 304 时使用缓存内容，返回 200 时更新缓存
+func fetch(ctx context.Context, client *http.Client, cache *HTTPCache, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	cached, err := cache.Get(rawURL)
+	if err != nil {
+		cached = nil
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, redactRequestError(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		if cached == nil {
+			return nil, errors.New("服务器返回304但本地没有缓存")
+		}
+		cached.CheckedAt = time.Now()
+		cache.put(rawURL, cached)
+		return cached.Body, nil
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return nil, &RetryAfterError{StatusCode: resp.StatusCode, Delay: delay}
+		}
+		return nil, fmt.Errorf("无效状态码: %d", resp.StatusCode)
+	default:
+		return nil, fmt.Errorf("无效状态码: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	if len(body) > maxResponseSize {
+		return nil, fmt.Errorf("响应超过%d字节", maxResponseSize)
+	}
+	if cache != nil {
+		now := time.Now()
+		entry := &HTTPCacheEntry{
+			URL:          redactedURLString(rawURL),
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			FetchedAt:    now,
+			CheckedAt:    now,
+			Body:         body,
+		}
+		if err := cache.put(rawURL, entry); err != nil {
+			log.Printf("%v\n", err)
+		}
+	}
+	return body, nil
+}
+
+// redactedURLString 返回去掉查询参数的 URL 字符串
+func redactedURLString(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return redactedURL(u)
+}
@@ -0,0 +1,211 @@
+package utiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFetchRevalidate 再次请求时附带 ETag 和 Last-Modified，服务器返回 304 时使用缓存
+func TestFetchRevalidate(t *testing.T) {
+	const etag = `"v1"`
+	const lastModified = "Mon, 01 Jun 2026 00:00:00 GMT"
+	tests := []struct {
+		name    string
+		headers map[string]string
+		match   func(r *http.Request) bool
+	}{
+		{
+			name:    "ETag",
+			headers: map[string]string{"ETag": etag},
+			match:   func(r *http.Request) bool { return r.Header.Get("If-None-Match") == etag },
+		},
+		{
+			name:    "Last-Modified",
+			headers: map[string]string{"Last-Modified": lastModified},
+			match:   func(r *http.Request) bool { return r.Header.Get("If-Modified-Since") == lastModified },
+		},
+	}
+	for _, tt := range tests {
+		requests, notModified := 0, 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if tt.match(r) {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			for k, v := range tt.headers {
+				w.Header().Set(k, v)
+			}
+			w.Write([]byte("203.0.113.10,"))
+		}))
+		cache := NewHTTPCache(t.TempDir())
+		url := srv.URL + "/ips?key=secret"
+
+		for i := 0; i < 3; i++ {
+			body, err := fetch(context.Background(), srv.Client(), cache, url)
+			if err != nil || string(body) != "203.0.113.10," {
+				t.Fatalf("%s: 第%d次请求返回 %q %v", tt.name, i+1, body, err)
+			}
+		}
+		srv.Close()
+		if requests != 3 || notModified != 2 {
+			t.Errorf("%s: 共 %d 次请求，其中 %d 次返回304，期望 3 次和 2 次", tt.name, requests, notModified)
+		}
+
+		entry, err := cache.Get(url)
+		if err != nil || entry == nil {
+			t.Fatalf("%s: 读取缓存失败: %v", tt.name, err)
+		}
+		if strings.Contains(entry.URL, "secret") {
+			t.Errorf("%s: 缓存的 URL 包含密钥: %s", tt.name, entry.URL)
+		}
+		if entry.CheckedAt.Before(entry.FetchedAt) {
+			t.Errorf("%s: 304 后未更新确认时间", tt.name)
+		}
+	}
+}
+
+// TestFetchNotModifiedWithoutCache 服务器返回 304 但缓存不存在或已损坏时返回错误
+func TestFetchNotModifiedWithoutCache(t *testing.T) {
+	var conditional bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != ""
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+	url := srv.URL + "/planet"
+
+	missing := NewHTTPCache(t.TempDir())
+	corrupt := NewHTTPCache(t.TempDir())
+	if err := os.WriteFile(corrupt.path(url), []byte("{损坏"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for name, cache := range map[string]*HTTPCache{"未启用缓存": nil, "缓存不存在": missing, "缓存已损坏": corrupt} {
+		body, err := fetch(context.Background(), srv.Client(), cache, url)
+		if err == nil || body != nil {
+			t.Errorf("%s: 返回 %q %v，期望错误", name, body, err)
+		}
+		if conditional {
+			t.Errorf("%s: 没有可用缓存时不应发送条件请求", name)
+		}
+	}
+	// 损坏的缓存不会被 304 覆盖为空内容
+	if data, _ := os.ReadFile(corrupt.path(url)); string(data) != "{损坏" {
+		t.Errorf("损坏的缓存被改写为 %q", data)
+	}
+}
+
+func TestFetchRetryAfter(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		status     int
+		retryAfter string
+		min, max   time.Duration
+	}{
+		{http.StatusTooManyRequests, "120", 120 * time.Second, 120 * time.Second},
+		{http.StatusServiceUnavailable, "0", 0, 0},
+		{http.StatusServiceUnavailable, "-30", 0, 0},
+		{http.StatusTooManyRequests, "86400", time.Hour, time.Hour},
+		{http.StatusServiceUnavailable, now.Add(10 * time.Minute).UTC().Format(http.TimeFormat), 9 * time.Minute, 10 * time.Minute},
+		{http.StatusTooManyRequests, now.Add(48 * time.Hour).UTC().Format(http.TimeFormat), time.Hour, time.Hour},
+		{http.StatusServiceUnavailable, now.Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", tt.retryAfter)
+			w.WriteHeader(tt.status)
+		}))
+		_, err := fetch(context.Background(), srv.Client(), nil, srv.URL)
+		srv.Close()
+
+		var retryErr *RetryAfterError
+		if !errors.As(err, &retryErr) {
+			t.Errorf("%d %q: 返回 %v，期望 RetryAfterError", tt.status, tt.retryAfter, err)
+			continue
+		}
+		if retryErr.StatusCode != tt.status || retryErr.Delay < tt.min || retryErr.Delay > tt.max {
+			t.Errorf("%d %q: 返回状态码 %d 等待 %v，期望 %d 和 [%v, %v]",
+				tt.status, tt.retryAfter, retryErr.StatusCode, retryErr.Delay, tt.status, tt.min, tt.max)
+		}
+	}
+
+	// 没有或无法解析 Retry-After 时按普通错误处理
+	for _, value := range []string{"", "soon"} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if value != "" {
+				w.Header().Set("Retry-After", value)
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		_, err := fetch(context.Background(), srv.Client(), nil, srv.URL)
+		srv.Close()
+		var retryErr *RetryAfterError
+		if err == nil || errors.As(err, &retryErr) {
+			t.Errorf("Retry-After %q: 返回 %v，期望普通错误", value, err)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"abc", 0, false},
+		{"30", 30 * time.Second, true},
+		{"3600", time.Hour, true},
+		{"3601", time.Hour, true},
+		{"-1", 0, true},
+		{"Mon, 01 Jun 2026 00:05:00 GMT", 5 * time.Minute, true},
+		{"Mon, 01 Jun 2026 02:00:00 GMT", time.Hour, true},
+		{"Sun, 31 May 2026 23:00:00 GMT", 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%q: 返回 %v %v，期望 %v %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFetchSizeLimit(t *testing.T) {
+	tests := []struct {
+		size int
+		ok   bool
+	}{
+		{maxResponseSize, true},
+		{maxResponseSize + 1, false},
+		{4 * maxResponseSize, false},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"big"`)
+			w.Write(bytes.Repeat([]byte("x"), tt.size))
+		}))
+		cache := NewHTTPCache(t.TempDir())
+		body, err := fetch(context.Background(), srv.Client(), cache, srv.URL)
+		srv.Close()
+		if (err == nil) != tt.ok {
+			t.Errorf("%d 字节: 返回错误 %v", tt.size, err)
+			continue
+		}
+		entry, _ := cache.Get(srv.URL)
+		if tt.ok && (len(body) != tt.size || entry == nil) {
+			t.Errorf("%d 字节: 返回 %d 字节，缓存 %v", tt.size, len(body), entry != nil)
+		}
+		if !tt.ok && entry != nil {
+			t.Errorf("%d 字节: 超长响应被写入缓存", tt.size)
+		}
+	}
+}
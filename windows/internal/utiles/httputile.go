@@ -0,0 +1,312 @@
+package utiles
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+	auth "github.com/onlypeng/zerotier-extend/windows/pkg/auth"
+)
+
+const (
+	defaultConnectTimeout = 10 * time.Second
+	defaultReadTimeout    = 30 * time.Second
+	defaultHTTPTimeout    = 60 * time.Second
+	defaultClockSkewWarn  = 30 * time.Second
+)
+
+// NewHTTPClient 根据配置创建请求服务器使用的 HTTP 客户端：配置了证书固定时只接受公钥匹配的 HTTPS 服务器，
+// 启用 hmac 认证时为每个请求签名
+func NewHTTPClient(cfg config.HTTPConfig, serverCfg config.ServerConfig) (*http.Client, error) {
+	pins := serverCfg.CertPins
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := proxyFunc(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	if len(pins) > 0 {
+		pinSet, err := parsePins(pins)
+		if err != nil {
+			return nil, err
+		}
+		// https 代理的 TLS 连接同样使用 TLSClientConfig，会被误用固定值校验
+		if strings.HasPrefix(cfg.Proxy, "https://") {
+			return nil, fmt.Errorf("证书公钥固定不支持与https代理同时使用")
+		}
+		// 固定值替代证书链和域名校验，适用于自签名证书
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = pinVerifier(pinSet)
+	}
+
+	connectTimeout := secondsOr(cfg.ConnectTimeout, defaultConnectTimeout)
+	readTimeout := secondsOr(cfg.ReadTimeout, defaultReadTimeout)
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = tlsConfig
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.ResponseHeaderTimeout = readTimeout
+	transport.DialContext = dialer.DialContext
+
+	var base http.RoundTripper = transport
+	signer, err := newSigner(serverCfg.Auth)
+	if err != nil {
+		return nil, err
+	}
+	if signer != nil {
+		base = &auth.Transport{Base: transport, Signer: signer}
+	}
+
+	return &http.Client{
+		Transport: &serverTransport{base: base, requireTLS: len(pins) > 0, readTimeout: readTimeout},
+		Timeout:   secondsOr(cfg.Timeout, defaultHTTPTimeout),
+	}, nil
+}
+
+// newSigner 根据认证配置创建签名器，未启用 hmac 时返回 nil
+func newSigner(cfg config.ServerAuthConfig) (*auth.Signer, error) {
+	switch cfg.Mode {
+	case "", "key":
+		return nil, nil
+	case "hmac":
+	default:
+		return nil, fmt.Errorf("不支持的认证方式: %s", cfg.Mode)
+	}
+	secret := cfg.Secret
+	if cfg.SecretFile != "" {
+		data, err := os.ReadFile(cfg.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取签名密钥失败: %w", err)
+		}
+		secret = strings.TrimSpace(string(data))
+	}
+	if secret == "" {
+		return nil, errors.New("hmac 认证需要配置 secret 或 secretFile")
+	}
+	return &auth.Signer{
+		KeyID:    cfg.KeyID,
+		Secret:   []byte(secret),
+		SkewWarn: secondsOr(cfg.ClockSkewWarn, defaultClockSkewWarn),
+	}, nil
+}
+
+// secondsOr 将秒数转换为时长，未配置时使用默认值
+func secondsOr(seconds int, def time.Duration) time.Duration {
+	if seconds <= 0 {
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// newTLSConfig 加载额外的根证书并设置最低TLS版本
+func newTLSConfig(cfg config.HTTPConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	switch cfg.MinTLSVersion {
+	case "", "1.2":
+	case "1.0":
+		tlsConfig.MinVersion = tls.VersionTLS10
+	case "1.1":
+		tlsConfig.MinVersion = tls.VersionTLS11
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("不支持的TLS版本: %s", cfg.MinTLSVersion)
+	}
+
+	if cfg.ClientCertFile != "" || cfg.ClientKeyFile != "" {
+		if cfg.ClientCertFile == "" || cfg.ClientKeyFile == "" {
+			return nil, errors.New("clientCertFile 和 clientKeyFile 需要同时配置")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(cfg.CAFilePaths) == 0 {
+		return tlsConfig, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, path := range cfg.CAFilePaths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("CA证书文件 %s 中没有有效的PEM证书", path)
+		}
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
+// proxyFunc 解析代理配置，为空时使用环境变量
+func proxyFunc(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	switch proxy {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case "direct", "none":
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("无效的代理地址: %q", proxy)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议: %s", u.Scheme)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// serverTransport 请求服务器使用的 Transport：配置了证书固定时拒绝明文请求（含重定向），
+// 将证书校验错误转换为易于理解的说明，并限制读取响应体时两次收到数据的间隔
+type serverTransport struct {
+	base        http.RoundTripper
+	requireTLS  bool
+	readTimeout time.Duration
+}
+
+func (t *serverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.requireTLS && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("已配置 server.certPins，拒绝非HTTPS请求: %s", redactedURL(req.URL))
+	}
+	if t.readTimeout <= 0 {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, describeTLSError(req.URL.Hostname(), err)
+		}
+		return resp, nil
+	}
+
+	// 等待响应头由 ResponseHeaderTimeout 限制；响应体读取超时时取消该请求，
+	// 只作用于当前请求，不影响连接复用
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, describeTLSError(req.URL.Hostname(), err)
+	}
+	resp.Body = newIdleTimeoutBody(resp.Body, t.readTimeout, cancel)
+	return resp, nil
+}
+
+// errReadTimeout 读取响应体时超过 readTimeout 未收到数据
+var errReadTimeout = errors.New("读取响应超时")
+
+// idleTimeoutBody 读取响应体时每次收到数据都重新计时，超时后取消请求，
+// 数据持续到达时不受总时长限制
+type idleTimeoutBody struct {
+	body     io.ReadCloser
+	timeout  time.Duration
+	timer    *time.Timer
+	cancel   context.CancelFunc
+	timedOut atomic.Bool
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutBody {
+	b := &idleTimeoutBody{body: body, timeout: timeout, cancel: cancel}
+	b.timer = time.AfterFunc(timeout, func() {
+		b.timedOut.Store(true)
+		cancel()
+	})
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if b.timedOut.Load() {
+		return n, fmt.Errorf("%w（%v内未收到数据）", errReadTimeout, b.timeout)
+	}
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.body.Close()
+	b.cancel()
+	return err
+}
+
+// CloseIdleConnections 关闭空闲连接
+func (t *serverTransport) CloseIdleConnections() {
+	if c, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// redactedURL 返回去掉查询参数和密码的 URL，避免 key 等密钥写入日志
+func redactedURL(u *url.URL) string {
+	c := *u
+	if c.RawQuery != "" {
+		c.RawQuery = "***"
+	}
+	return c.Redacted()
+}
+
+// redactRequestError 去掉请求错误中 URL 的查询参数
+func redactRequestError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, perr := url.Parse(urlErr.URL); perr == nil {
+			urlErr.URL = redactedURL(u)
+		}
+	}
+	return err
+}
+
+// describeTLSError 识别常见的 TLS 校验失败原因，其他错误原样返回
+func describeTLSError(host string, err error) error {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		invalidErr       x509.CertificateInvalidError
+		recordErr        tls.RecordHeaderError
+	)
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("TLS证书校验失败: %s 的证书由不受信任的机构签发，私有CA请配置 http.caFilePaths: %w", host, err)
+	case errors.As(err, &hostnameErr):
+		return fmt.Errorf("TLS证书校验失败: 证书与域名 %s 不匹配: %w", host, err)
+	case errors.As(err, &invalidErr):
+		reason := "证书无效"
+		if invalidErr.Reason == x509.Expired {
+			reason = "证书已过期或尚未生效，请同时检查本机时间"
+		}
+		return fmt.Errorf("TLS证书校验失败: %s %s: %w", host, reason, err)
+	case errors.As(err, &recordErr):
+		return fmt.Errorf("TLS握手失败: %s 返回的不是TLS数据，请检查地址是否应为http:// : %w", host, err)
+	case strings.Contains(err.Error(), "remote error: tls: certificate required"):
+		return fmt.Errorf("TLS握手失败: %s 要求客户端证书，请配置 http.clientCertFile 和 http.clientKeyFile: %w", host, err)
+	case strings.Contains(err.Error(), "remote error: tls: bad certificate"),
+		strings.Contains(err.Error(), "remote error: tls: revoked certificate"),
+		strings.Contains(err.Error(), "remote error: tls: unknown certificate authority"):
+		return fmt.Errorf("TLS握手失败: %s 拒绝了客户端证书（可能已吊销或不受信任）: %w", host, err)
+	case strings.Contains(err.Error(), "protocol version"):
+		return fmt.Errorf("TLS握手失败: %s 不支持要求的TLS版本(http.minTLSVersion): %w", host, err)
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package utiles
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+// readTimeoutClient 创建 readTimeout 为 1 秒的客户端
+func readTimeoutClient(t *testing.T) *http.Client {
+	t.Helper()
+	client, err := NewHTTPClient(config.HTTPConfig{ReadTimeout: 1, Timeout: 10, Proxy: "direct"}, config.ServerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func get(client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// TestReadTimeoutKeepsIdleConnections 空闲连接超过 readTimeout 后仍可复用
+func TestReadTimeoutKeepsIdleConnections(t *testing.T) {
+	var mu sync.Mutex
+	remotes := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		remotes[r.RemoteAddr] = true
+		mu.Unlock()
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := readTimeoutClient(t)
+	for i := 0; i < 2; i++ {
+		if body, err := get(client, srv.URL); err != nil || string(body) != "ok" {
+			t.Fatalf("第%d次请求: %q %v", i+1, body, err)
+		}
+		time.Sleep(1500 * time.Millisecond)
+	}
+	if len(remotes) != 1 {
+		t.Errorf("使用了 %d 个连接，期望复用同一个连接", len(remotes))
+	}
+}
+
+func TestReadTimeoutBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		pause := 600 * time.Millisecond
+		if r.URL.Path == "/stall" {
+			pause = 2 * time.Second
+		}
+		for i := 0; i < 3; i++ {
+			w.Write([]byte("data"))
+			flusher.Flush()
+			select {
+			case <-time.After(pause):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	client := readTimeoutClient(t)
+
+	// 数据持续到达时总时长可超过 readTimeout
+	if body, err := get(client, srv.URL+"/slow"); err != nil || string(body) != "datadatadata" {
+		t.Errorf("持续到达的响应读取失败: %q %v", body, err)
+	}
+	// 超过 readTimeout 未收到数据时中止
+	start := time.Now()
+	_, err := get(client, srv.URL+"/stall")
+	if !errors.Is(err, errReadTimeout) {
+		t.Errorf("错误为 %v，期望读取超时", err)
+	}
+	if elapsed := time.Since(start); elapsed > 1800*time.Millisecond {
+		t.Errorf("读取超时在 %v 后才返回", elapsed)
+	}
+}
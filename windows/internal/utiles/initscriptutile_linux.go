@@ -0,0 +1,177 @@
+//go:build linux
+
+package utiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	initScriptDir     = "/etc/init.d"
+	initScriptRetries = 3               // 启动/停止命令失败时的重试次数
+	initScriptDelay   = 5 * time.Second // 每次重试之间的等待时间
+)
+
+var _ ServiceController = (*InitScriptServiceManager)(nil)
+
+// InitScriptServiceManager 通过 init 脚本控制 OpenWrt(procd)、OpenRC 和 SysV 服务
+type InitScriptServiceManager struct {
+	ServiceName string
+	InitType    string // procd、openrc 或 sysv
+}
+
+// NewInitScriptServiceManager 创建 init 脚本服务管理器并检查服务是否存在
+func NewInitScriptServiceManager(initType, name string) (*InitScriptServiceManager, error) {
+	im := &InitScriptServiceManager{ServiceName: name, InitType: initType}
+	if initType == "openrc" {
+		if _, err := exec.LookPath("rc-service"); err != nil {
+			return nil, fmt.Errorf("未找到 rc-service: %v", err)
+		}
+	}
+	info, err := os.Stat(im.scriptPath())
+	if err != nil {
+		return nil, fmt.Errorf("服务 %s 不存在: %v", name, err)
+	}
+	if info.Mode()&0111 == 0 {
+		return nil, fmt.Errorf("服务脚本 %s 不可执行", im.scriptPath())
+	}
+	return im, nil
+}
+
+// Name 返回服务名称
+func (im *InitScriptServiceManager) Name() string {
+	return im.ServiceName
+}
+
+// scriptPath 返回服务 init 脚本路径
+func (im *InitScriptServiceManager) scriptPath() string {
+	return filepath.Join(initScriptDir, im.ServiceName)
+}
+
+// command 构造执行指定动作的命令
+func (im *InitScriptServiceManager) command(ctx context.Context, action string) *exec.Cmd {
+	if im.InitType == "openrc" {
+		return exec.CommandContext(ctx, "rc-service", im.ServiceName, action)
+	}
+	return exec.CommandContext(ctx, im.scriptPath(), action)
+}
+
+// Status 返回服务当前状态，优先解析输出文本，其次按 LSB 退出码判断
+func (im *InitScriptServiceManager) Status() (ServiceState, error) {
+	out, err := im.command(context.Background(), "status").CombinedOutput()
+	text := strings.ToLower(string(out))
+	switch {
+	case strings.Contains(text, "starting"):
+		return StateStartPending, nil
+	case strings.Contains(text, "stopping"):
+		return StateStopPending, nil
+	case strings.Contains(text, "not running"), strings.Contains(text, "inactive"),
+		strings.Contains(text, "stopped"), strings.Contains(text, "crashed"):
+		return StateStopped, nil
+	case strings.Contains(text, "running"), strings.Contains(text, "started"):
+		return StateRunning, nil
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return StateRunning, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 3:
+		return StateStopped, nil
+	case errors.As(err, &exitErr):
+		return StateUnknown, nil
+	default:
+		return StateUnknown, fmt.Errorf("查询服务状态失败: %v", err)
+	}
+}
+
+// runWithRetry 执行服务动作，失败时按固定间隔重试，ctx 取消时停止重试
+func (im *InitScriptServiceManager) runWithRetry(ctx context.Context, action string) error {
+	var lastErr error
+	for i := 1; i <= initScriptRetries; i++ {
+		out, err := im.command(ctx, action).CombinedOutput()
+		if err == nil {
+			return nil
+		}
+		lastErr = fmt.Errorf("%v (%s)", err, strings.TrimSpace(string(out)))
+		if i < initScriptRetries {
+			log.Printf("服务 %s %s 第 %d 次失败，等待%v后重试: %v", im.ServiceName, action, i, initScriptDelay, lastErr)
+			select {
+			case <-time.After(initScriptDelay):
+			case <-ctx.Done():
+				return fmt.Errorf("%v，重试被取消: %w", lastErr, ctx.Err())
+			}
+		}
+	}
+	return lastErr
+}
+
+// Start 启动服务（仅在非运行状态下启动）
+func (im *InitScriptServiceManager) Start(ctx context.Context) error {
+	state, err := im.Status()
+	if err != nil {
+		return err
+	}
+	if state == StateRunning {
+		return fmt.Errorf("服务已在运行，无需重复启动")
+	}
+	if err := im.runWithRetry(ctx, "start"); err != nil {
+		return fmt.Errorf("启动服务失败: %v", err)
+	}
+	return im.WaitForStatus(ctx, StateRunning, serviceWaitTimeout)
+}
+
+// Stop 停止服务（仅在运行状态下停止）
+func (im *InitScriptServiceManager) Stop(ctx context.Context) error {
+	state, err := im.Status()
+	if err != nil {
+		return err
+	}
+	if state == StateStopped {
+		return fmt.Errorf("服务已停止，无需重复停止")
+	}
+	if err := im.runWithRetry(ctx, "stop"); err != nil {
+		return fmt.Errorf("发送停止命令失败: %v", err)
+	}
+	return im.WaitForStatus(ctx, StateStopped, serviceWaitTimeout)
+}
+
+// Restart 重启服务（先停止再启动，与 Windows 实现保持一致）
+func (im *InitScriptServiceManager) Restart(ctx context.Context) error {
+	state, err := im.Status()
+	if err != nil {
+		return err
+	}
+	if state == StateRunning {
+		if err := im.Stop(ctx); err != nil {
+			return fmt.Errorf("重启失败（停止失败）: %v", err)
+		}
+	}
+	if err := im.Start(ctx); err != nil {
+		return fmt.Errorf("重启失败（启动失败）: %v", err)
+	}
+	return nil
+}
+
+// RestartTimeout 停止和启动各自包含命令失败时的重试等待
+func (im *InitScriptServiceManager) RestartTimeout() time.Duration {
+	return 2 * (serviceWaitTimeout + (initScriptRetries-1)*initScriptDelay)
+}
+
+// WaitForStatus 等待服务达到指定状态
+func (im *InitScriptServiceManager) WaitForStatus(ctx context.Context, target ServiceState, timeout time.Duration) error {
+	return waitForState(ctx, im, target, timeout)
+}
+
+// Close init 脚本后端无需释放资源
+func (im *InitScriptServiceManager) Close() error {
+	return nil
+}
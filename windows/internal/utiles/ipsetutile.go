@@ -0,0 +1,92 @@
+package utiles
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// IPSet 域名解析结果，按地址族分别去重排序，比较时与解析顺序无关
+type IPSet struct {
+	V4 []netip.Addr
+	V6 []netip.Addr
+}
+
+// NewIPSet 由 IP 列表创建规范化的 IPSet
+func NewIPSet(ips []netip.Addr) IPSet {
+	var set IPSet
+	seen := make(map[netip.Addr]bool)
+	for _, ip := range ips {
+		ip = ip.Unmap()
+		if !ip.IsValid() || seen[ip] {
+			continue
+		}
+		seen[ip] = true
+		if ip.Is4() {
+			set.V4 = append(set.V4, ip)
+		} else {
+			set.V6 = append(set.V6, ip)
+		}
+	}
+	sortAddrs(set.V4)
+	sortAddrs(set.V6)
+	return set
+}
+
+// ParseIPSet 解析以逗号分隔的 IP 记录，兼容旧版 "ipv4,ipv6" 格式
+func ParseIPSet(s string) (IPSet, error) {
+	var addrs []netip.Addr
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return IPSet{}, fmt.Errorf("无效的IP地址 %q: %w", field, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return NewIPSet(addrs), nil
+}
+
+func sortAddrs(addrs []netip.Addr) {
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Less(addrs[j]) })
+}
+
+// All 返回全部地址，IPv4 在前
+func (s IPSet) All() []netip.Addr {
+	all := make([]netip.Addr, 0, len(s.V4)+len(s.V6))
+	all = append(all, s.V4...)
+	return append(all, s.V6...)
+}
+
+// IsEmpty 判断是否没有任何地址
+func (s IPSet) IsEmpty() bool {
+	return len(s.V4) == 0 && len(s.V6) == 0
+}
+
+// Equal 判断两个集合是否包含相同的地址
+func (s IPSet) Equal(o IPSet) bool {
+	a, b := s.All(), o.All()
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// String 返回以逗号分隔的规范化记录，用于保存和日志
+func (s IPSet) String() string {
+	all := s.All()
+	fields := make([]string, len(all))
+	for i, ip := range all {
+		fields[i] = ip.String()
+	}
+	return strings.Join(fields, ",")
+}
@@ -1,33 +1,28 @@
 package utiles
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net"
 	"net/http"
 	"os"
 	"time"
+
+	resolver "github.com/onlypeng/zerotier-extend/windows/internal/resolver"
 )
 
-func GetCurrentIPs(domain string) (string, error) {
-	addrs, err := net.LookupIP(domain)
+// GetCurrentIPs 使用指定解析器解析域名的全部 IPv4/IPv6 地址，同时返回记录的 TTL（未知时为 0）
+func GetCurrentIPs(ctx context.Context, r resolver.Resolver, domain string) (IPSet, time.Duration, error) {
+	result, err := r.Lookup(ctx, domain)
 	if err != nil {
-		return "", fmt.Errorf("DNS查询失败: %w", err)
+		return IPSet{}, 0, fmt.Errorf("DNS查询失败: %w", err)
 	}
-	ipv4, ipv6 := "", ""
-	for _, ip := range addrs {
-		if ip.To4() != nil && ipv4 == "" {
-			ipv4 = ip.String()
-		} else if len(ip) == net.IPv6len && ipv6 == "" {
-			ipv6 = ip.String()
-		}
-		if ipv4 != "" && ipv6 != "" {
-			break
-		}
+	set := NewIPSet(result.IPs)
+	if set.IsEmpty() {
+		return IPSet{}, 0, fmt.Errorf("域名 %s 未解析到IP", domain)
 	}
-	return ipv4 + "," + ipv6, nil
+	return set, result.TTL, nil
 }
 
 func GetLocalIPs(ipFilePath string) (string, error) {
@@ -41,43 +36,44 @@ func GetLocalIPs(ipFilePath string) (string, error) {
 	return string(data), nil
 }
 
-func GetServerIPs(serverIPsUrl string) (string, error) {
-	resp, err := http.Get(serverIPsUrl)
+// GetServerIPs 获取服务器记录的IP，内容未变化（304）时返回缓存的内容
+func GetServerIPs(ctx context.Context, client *http.Client, cache *HTTPCache, serverIPsUrl string) (string, error) {
+	body, err := fetch(ctx, client, cache, serverIPsUrl)
 	if err != nil {
 		return "", fmt.Errorf("服务器状态查询失败: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("无效状态码: %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	serverIPs := string(body)
-	return serverIPs, nil
+	return string(body), nil
 }
 
-// 查询并等待服务器planet文件更新
-func WaitForPlanetFileUpdate(serverIPsUrl, serverIPsPath string, checkInterval int) (string, error) {
+// 查询并等待服务器planet文件更新，服务器要求稍后重试时按 Retry-After 等待，ctx 取消时返回
+func WaitForPlanetFileUpdate(ctx context.Context, client *http.Client, cache *HTTPCache, serverIPsUrl, serverIPsPath string, checkInterval int) (string, error) {
 	for {
-		serverIPs, err := GetServerIPs(serverIPsUrl)
-		if err != nil {
-			return "", fmt.Errorf("获取服务器IP失败: %v", err)
-		}
-		localServerIPs, err := GetLocalIPs(serverIPsPath)
-		if err != nil {
-			return "", fmt.Errorf("获取本地服务器IP失败: %v", err)
+		wait := time.Duration(checkInterval) * time.Second
+		serverIPs, err := GetServerIPs(ctx, client, cache, serverIPsUrl)
+		var retryErr *RetryAfterError
+		switch {
+		case errors.As(err, &retryErr):
+			log.Printf("%v", retryErr)
+			if retryErr.Delay > wait {
+				wait = retryErr.Delay
+			}
+		case err != nil:
+			return "", fmt.Errorf("获取服务器IP失败: %w", err)
+		default:
+			localServerIPs, err := GetLocalIPs(serverIPsPath)
+			if err != nil {
+				return "", fmt.Errorf("获取本地服务器IP失败: %v", err)
+			}
+			if localServerIPs != serverIPs {
+				return serverIPs, nil
+			}
+			log.Printf("服务器文件未更新,%v后重试", wait)
 		}
-		if localServerIPs == serverIPs {
-			log.Printf("服务器文件未更新,%d秒后重试", checkInterval)
-			time.Sleep(time.Duration(checkInterval) * time.Second)
-			continue
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return "", fmt.Errorf("等待服务器文件更新被取消: %w", ctx.Err())
 		}
-		return serverIPs, nil
 	}
 }
 func ReplacePlanetFile(planetPath string) error {
@@ -96,24 +92,26 @@ func ReplacePlanetFile(planetPath string) error {
 	return nil
 }
 
-func Download(url, planetPath string) error {
-	tmpFile := planetPath + ".tmp"
-	resp, err := http.Get(url)
-	if err != nil {
-		return fmt.Errorf("请求失败: %w", err)
+// RestorePlanetBackup 使用 ReplacePlanetFile 生成的 .bak 文件恢复 planet
+func RestorePlanetBackup(planetPath string) error {
+	bakPath := planetPath + ".bak"
+	if _, err := os.Stat(bakPath); err != nil {
+		return fmt.Errorf("备份文件不可用: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("状态码错误: %d", resp.StatusCode)
+	if err := CopyFile(bakPath, planetPath); err != nil {
+		return fmt.Errorf("恢复备份失败: %w", err)
 	}
-	out, err := os.Create(tmpFile)
+	return nil
+}
+
+// Download 下载planet文件到 planetPath.tmp，内容未变化（304）时使用缓存的文件
+func Download(ctx context.Context, client *http.Client, cache *HTTPCache, url, planetPath string) error {
+	body, err := fetch(ctx, client, cache, url)
 	if err != nil {
-		return fmt.Errorf("创建文件失败: %w", err)
+		return fmt.Errorf("请求失败: %w", err)
 	}
-	defer out.Close()
-
-	if _, err := io.Copy(out, resp.Body); err != nil {
+	if err := os.WriteFile(planetPath+".tmp", body, 0644); err != nil {
+		os.Remove(planetPath + ".tmp")
 		return fmt.Errorf("写入文件失败: %w", err)
 	}
 	return nil
@@ -0,0 +1,340 @@
+package utiles
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Windows 等系统可能没有时区数据库
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+// MaintenanceSchedule 维护窗口，只有窗口内允许替换planet并重启服务
+type MaintenanceSchedule struct {
+	Location *time.Location
+	windows  []window
+}
+
+// window 单个维护窗口
+type window interface {
+	// contains 判断时间是否在窗口内
+	contains(t time.Time) bool
+	// nextStart 返回 t 之后（含）窗口下一次开始的时间
+	nextStart(t time.Time) (time.Time, bool)
+	String() string
+}
+
+// NewMaintenanceSchedule 解析维护窗口配置，未配置窗口时任何时间都允许
+func NewMaintenanceSchedule(cfg config.MaintenanceConfig) (*MaintenanceSchedule, error) {
+	loc := time.Local
+	if cfg.Timezone != "" {
+		l, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("无效的时区 %q: %v", cfg.Timezone, err)
+		}
+		loc = l
+	}
+	s := &MaintenanceSchedule{Location: loc}
+	for i, wc := range cfg.Windows {
+		w, err := parseWindow(wc)
+		if err != nil {
+			return nil, fmt.Errorf("维护窗口%d无效: %v", i+1, err)
+		}
+		s.windows = append(s.windows, w)
+	}
+	return s, nil
+}
+
+// Enabled 是否配置了维护窗口
+func (s *MaintenanceSchedule) Enabled() bool {
+	return len(s.windows) > 0
+}
+
+// Allowed 判断给定时间是否允许执行重启等中断性操作
+func (s *MaintenanceSchedule) Allowed(t time.Time) bool {
+	if !s.Enabled() {
+		return true
+	}
+	t = t.In(s.Location)
+	for _, w := range s.windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextWindow 返回 t 之后最近一个维护窗口的开始时间，31天内没有窗口时返回 false
+func (s *MaintenanceSchedule) NextWindow(t time.Time) (time.Time, bool) {
+	t = t.In(s.Location)
+	var next time.Time
+	found := false
+	for _, w := range s.windows {
+		if start, ok := w.nextStart(t); ok && (!found || start.Before(next)) {
+			next, found = start, true
+		}
+	}
+	return next, found
+}
+
+// String 返回维护窗口描述
+func (s *MaintenanceSchedule) String() string {
+	if !s.Enabled() {
+		return "不限"
+	}
+	parts := make([]string, len(s.windows))
+	for i, w := range s.windows {
+		parts[i] = w.String()
+	}
+	return strings.Join(parts, "; ") + " (" + s.Location.String() + ")"
+}
+
+// parseWindow 根据配置创建 cron 窗口或按星期/时段的窗口
+func parseWindow(wc config.MaintenanceWindow) (window, error) {
+	if wc.Cron != "" {
+		if wc.Days != "" || wc.Start != "" || wc.End != "" {
+			return nil, fmt.Errorf("cron 不能与 days/start/end 同时配置")
+		}
+		if wc.Duration <= 0 {
+			return nil, fmt.Errorf("cron 窗口需要配置 duration（分钟）")
+		}
+		spec, err := parseCron(wc.Cron)
+		if err != nil {
+			return nil, err
+		}
+		return &cronWindow{spec: spec, expr: wc.Cron, duration: time.Duration(wc.Duration) * time.Minute}, nil
+	}
+
+	days, err := parseField(wc.Days, 0, 6, weekdayNames)
+	if err != nil {
+		return nil, fmt.Errorf("days: %v", err)
+	}
+	start, err := parseClock(wc.Start)
+	if err != nil {
+		return nil, fmt.Errorf("start: %v", err)
+	}
+	end, err := parseClock(wc.End)
+	if err != nil {
+		return nil, fmt.Errorf("end: %v", err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("start 与 end 不能相同")
+	}
+	return &dayWindow{days: days, daysText: wc.Days, start: start, end: end}, nil
+}
+
+// parseClock 解析 HH:MM，返回当天的分钟数，允许 24:00
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("时间格式应为 HH:MM: %q", s)
+	}
+	hour, err1 := strconv.Atoi(h)
+	minute, err2 := strconv.Atoi(m)
+	if err1 != nil || err2 != nil || hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("无效的时间: %q", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// dayWindow 指定星期的时段，end 不大于 start 时跨越午夜，星期以开始当天为准
+type dayWindow struct {
+	days       uint64
+	daysText   string
+	start, end int
+}
+
+func (w *dayWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	today := w.days&(1<<uint(t.Weekday())) != 0
+	if w.start < w.end {
+		return today && minute >= w.start && minute < w.end
+	}
+	yesterday := w.days&(1<<uint((t.Weekday()+6)%7)) != 0
+	return (today && minute >= w.start) || (yesterday && minute < w.end)
+}
+
+func (w *dayWindow) nextStart(t time.Time) (time.Time, bool) {
+	if w.contains(t) {
+		return t, true
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i <= 7; i++ {
+		d := day.AddDate(0, 0, i)
+		start := d.Add(time.Duration(w.start) * time.Minute)
+		if w.days&(1<<uint(d.Weekday())) != 0 && !start.Before(t) {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (w *dayWindow) String() string {
+	days := w.daysText
+	if days == "" {
+		days = "每天"
+	}
+	return fmt.Sprintf("%s %02d:%02d-%02d:%02d", days, w.start/60, w.start%60, w.end/60, w.end%60)
+}
+
+// cronWindow 在 cron 表达式匹配的时刻开始、持续 duration 的窗口
+type cronWindow struct {
+	spec     *cronSpec
+	expr     string
+	duration time.Duration
+}
+
+func (w *cronWindow) contains(t time.Time) bool {
+	// 向前查找 duration 内是否有匹配的开始时刻
+	t = t.Truncate(time.Minute)
+	for d := time.Duration(0); d < w.duration; d += time.Minute {
+		if w.spec.match(t.Add(-d)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *cronWindow) nextStart(t time.Time) (time.Time, bool) {
+	if w.contains(t) {
+		return t, true
+	}
+	m := t.Truncate(time.Minute)
+	if m.Before(t) {
+		m = m.Add(time.Minute)
+	}
+	for limit := m.AddDate(0, 0, 31); m.Before(limit); m = m.Add(time.Minute) {
+		if w.spec.match(m) {
+			return m, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (w *cronWindow) String() string {
+	return fmt.Sprintf("cron(%s) %v", w.expr, w.duration)
+}
+
+// cronSpec 标准5字段 cron 表达式：分 时 日 月 星期
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+var (
+	weekdayNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+	monthNames   = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+)
+
+// parseCron 解析 cron 表达式，支持 *、列表、范围、步长以及月份和星期的英文缩写
+func parseCron(expr string) (*cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式需要5个字段: %q", expr)
+	}
+	spec := &cronSpec{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	var err error
+	if spec.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("分钟字段: %v", err)
+	}
+	if spec.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("小时字段: %v", err)
+	}
+	if spec.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("日期字段: %v", err)
+	}
+	if spec.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("月份字段: %v", err)
+	}
+	// 星期允许 7 表示周日
+	if spec.dow, err = parseField(fields[4], 0, 7, weekdayNames); err != nil {
+		return nil, fmt.Errorf("星期字段: %v", err)
+	}
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1
+	}
+	return spec, nil
+}
+
+// match 判断时间（精确到分钟）是否匹配；日期和星期都被限制时满足其一即可
+func (c *cronSpec) match(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 || c.hour&(1<<uint(t.Hour())) == 0 || c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowMatch
+	case c.dowAny:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
+
+// parseField 解析逗号分隔的值、范围（可跨越上限回绕，如 Fri-Mon）和步长，空字符串或 * 表示全部
+func parseField(s string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	if s == "" {
+		s = "*"
+	}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("无效的步长: %q", part)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseValue(a, min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = parseValue(b, min, max, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+
+		span := hi - lo
+		if span < 0 {
+			span += max - min + 1
+		}
+		for i := 0; i <= span; i += step {
+			v := lo + i
+			if v > max {
+				v -= max - min + 1
+			}
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseValue 解析单个数值或名称
+func parseValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("无效的值: %q（范围 %d-%d）", s, min, max)
+	}
+	return v, nil
+}
@@ -0,0 +1,288 @@
+package utiles
+
+import (
+	"testing"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+// at 返回 2026 年 6 月指定日期和时间（UTC），6 月 1 日为周一
+func at(day int, clock string) time.Time {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		panic(err)
+	}
+	return time.Date(2026, time.June, day, t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+func mustSchedule(t *testing.T, windows ...config.MaintenanceWindow) *MaintenanceSchedule {
+	t.Helper()
+	s, err := NewMaintenanceSchedule(config.MaintenanceConfig{Windows: windows, Timezone: "UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestParseField(t *testing.T) {
+	bits := func(vs ...int) uint64 {
+		var b uint64
+		for _, v := range vs {
+			b |= 1 << uint(v)
+		}
+		return b
+	}
+	tests := []struct {
+		field    string
+		min, max int
+		names    map[string]int
+		want     uint64
+	}{
+		{"*", 0, 6, nil, bits(0, 1, 2, 3, 4, 5, 6)},
+		{"", 0, 6, nil, bits(0, 1, 2, 3, 4, 5, 6)},
+		{"Mon-Fri", 0, 6, weekdayNames, bits(1, 2, 3, 4, 5)},
+		{"Fri-Mon", 0, 6, weekdayNames, bits(5, 6, 0, 1)},
+		{"sat,SUN", 0, 6, weekdayNames, bits(6, 0)},
+		{"Sat-Sun", 0, 7, weekdayNames, bits(6, 7, 0)},
+		{"1-10/3", 0, 59, nil, bits(1, 4, 7, 10)},
+		{"5/20", 0, 59, nil, bits(5, 25, 45)},
+		{"*/6", 0, 23, nil, bits(0, 6, 12, 18)},
+		{"22-2", 0, 23, nil, bits(22, 23, 0, 1, 2)},
+		{"nov-feb", 1, 12, monthNames, bits(11, 12, 1, 2)},
+	}
+	for _, tt := range tests {
+		got, err := parseField(tt.field, tt.min, tt.max, tt.names)
+		if err != nil {
+			t.Errorf("%q: %v", tt.field, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: 结果为 %b，期望 %b", tt.field, got, tt.want)
+		}
+	}
+
+	for _, field := range []string{"7", "Foo", "1-", "*/0", "1/x", "-1"} {
+		if _, err := parseField(field, 0, 6, weekdayNames); err == nil {
+			t.Errorf("%q: 未返回错误", field)
+		}
+	}
+}
+
+func TestDayWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		window config.MaintenanceWindow
+		times  map[time.Time]bool
+	}{
+		{
+			name:   "工作日夜间跨午夜",
+			window: config.MaintenanceWindow{Days: "Mon-Fri", Start: "22:00", End: "06:00"},
+			times: map[time.Time]bool{
+				at(1, "23:00"): true,  // 周一晚
+				at(2, "05:59"): true,  // 周一开始的窗口延续到周二早
+				at(2, "06:00"): false, // 结束时间不含
+				at(5, "21:59"): false,
+				at(6, "01:00"): true,  // 周五开始的窗口延续到周六
+				at(6, "23:00"): false, // 周六不开始新窗口
+				at(8, "01:00"): false, // 周日未开始窗口
+			},
+		},
+		{
+			name:   "星期范围回绕",
+			window: config.MaintenanceWindow{Days: "Fri-Mon", Start: "09:00", End: "17:00"},
+			times: map[time.Time]bool{
+				at(5, "09:00"): true,
+				at(6, "12:00"): true,
+				at(7, "12:00"): true,
+				at(8, "16:59"): true,
+				at(9, "12:00"): false,
+				at(4, "12:00"): false,
+			},
+		},
+		{
+			name:   "每天",
+			window: config.MaintenanceWindow{Start: "02:00", End: "04:00"},
+			times: map[time.Time]bool{
+				at(3, "02:00"): true,
+				at(7, "03:59"): true,
+				at(7, "04:00"): false,
+				at(7, "01:59"): false,
+			},
+		},
+		{
+			name:   "全天到24:00",
+			window: config.MaintenanceWindow{Days: "Sat,Sun", Start: "00:00", End: "24:00"},
+			times: map[time.Time]bool{
+				at(6, "00:00"): true,
+				at(7, "23:59"): true,
+				at(8, "00:00"): false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		s := mustSchedule(t, tt.window)
+		for tm, want := range tt.times {
+			if got := s.Allowed(tm); got != want {
+				t.Errorf("%s: %s 允许=%v，期望 %v", tt.name, tm.Format("Mon 01-02 15:04"), got, want)
+			}
+		}
+	}
+}
+
+func TestCronWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		window config.MaintenanceWindow
+		times  map[time.Time]bool
+	}{
+		{
+			name:   "每周六凌晨两小时",
+			window: config.MaintenanceWindow{Cron: "0 3 * * Sat", Duration: 120},
+			times: map[time.Time]bool{
+				at(6, "02:59"): false,
+				at(6, "03:00"): true,
+				at(6, "04:59"): true,
+				at(6, "05:00"): false,
+				at(7, "03:30"): false,
+			},
+		},
+		{
+			name:   "星期7表示周日",
+			window: config.MaintenanceWindow{Cron: "0 3 * * 7", Duration: 60},
+			times: map[time.Time]bool{
+				at(7, "03:30"): true,
+				at(6, "03:30"): false,
+			},
+		},
+		{
+			name:   "星期0表示周日",
+			window: config.MaintenanceWindow{Cron: "0 3 * * 0", Duration: 60},
+			times: map[time.Time]bool{
+				at(14, "03:30"): true,
+				at(15, "03:30"): false,
+			},
+		},
+		{
+			name:   "日期与星期同时限制时满足其一即可",
+			window: config.MaintenanceWindow{Cron: "0 3 10 * Mon", Duration: 60},
+			times: map[time.Time]bool{
+				at(10, "03:30"): true,  // 10日（周三）
+				at(8, "03:30"):  true,  // 周一
+				at(9, "03:30"):  false, // 周二9日
+			},
+		},
+		{
+			name:   "只限制日期",
+			window: config.MaintenanceWindow{Cron: "0 3 10 * *", Duration: 60},
+			times: map[time.Time]bool{
+				at(10, "03:30"): true,
+				at(8, "03:30"):  false,
+			},
+		},
+		{
+			name:   "步长与范围",
+			window: config.MaintenanceWindow{Cron: "*/30 1-2 * * *", Duration: 10},
+			times: map[time.Time]bool{
+				at(3, "01:05"): true,
+				at(3, "01:35"): true,
+				at(3, "02:39"): true,
+				at(3, "02:40"): false,
+				at(3, "03:00"): false,
+			},
+		},
+		{
+			name:   "跨午夜及星期回绕",
+			window: config.MaintenanceWindow{Cron: "0 22 * * Fri-Mon", Duration: 600},
+			times: map[time.Time]bool{
+				at(7, "23:00"): true, // 周日
+				at(9, "07:59"): true, // 周一晚开始的窗口延续到周二早
+				at(9, "08:00"): false,
+				at(9, "22:30"): false, // 周二不开始
+			},
+		},
+		{
+			name:   "月份名称",
+			window: config.MaintenanceWindow{Cron: "0 0 1 jan,jun *", Duration: 60},
+			times: map[time.Time]bool{
+				at(1, "00:30"): true,
+				at(2, "00:30"): false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		s := mustSchedule(t, tt.window)
+		for tm, want := range tt.times {
+			if got := s.Allowed(tm); got != want {
+				t.Errorf("%s: %s 允许=%v，期望 %v", tt.name, tm.Format("Mon 01-02 15:04"), got, want)
+			}
+		}
+	}
+}
+
+func TestMaintenanceInvalid(t *testing.T) {
+	invalid := []config.MaintenanceWindow{
+		{Cron: "0 3 * *", Duration: 60},
+		{Cron: "60 3 * * *", Duration: 60},
+		{Cron: "0 3 * * Foo", Duration: 60},
+		{Cron: "0 3 * * *"},
+		{Cron: "0 3 * * *", Duration: 60, Days: "Mon"},
+		{Start: "22:00", End: "22:00"},
+		{Start: "25:00", End: "06:00"},
+		{Start: "22:00"},
+		{Days: "Mon-Sun7", Start: "01:00", End: "02:00"},
+	}
+	for _, w := range invalid {
+		if _, err := NewMaintenanceSchedule(config.MaintenanceConfig{Windows: []config.MaintenanceWindow{w}}); err == nil {
+			t.Errorf("%+v: 未返回错误", w)
+		}
+	}
+	if _, err := NewMaintenanceSchedule(config.MaintenanceConfig{Timezone: "Mars/Base"}); err == nil {
+		t.Error("无效时区未返回错误")
+	}
+}
+
+func TestMaintenanceNextWindowAndTimezone(t *testing.T) {
+	s := mustSchedule(t,
+		config.MaintenanceWindow{Days: "Mon-Fri", Start: "22:00", End: "06:00"},
+		config.MaintenanceWindow{Cron: "0 3 * * Sat", Duration: 120},
+	)
+	tests := []struct {
+		now, want time.Time
+	}{
+		{at(6, "12:00"), at(8, "22:00")},   // 周六中午 -> 周一晚
+		{at(6, "02:00"), at(6, "02:00")},   // 周五开始的窗口内
+		{at(5, "23:00"), at(5, "23:00")},   // 窗口内返回当前时间
+		{at(6, "06:30"), at(8, "22:00")},   // 周六窗口结束后
+		{at(2, "07:00"), at(2, "22:00")},   // 工作日白天
+		{at(5, "07:00"), at(5, "22:00")},   // 周五白天早于周六 cron
+		{at(13, "05:30"), at(13, "05:30")}, // 周五开始的窗口与 cron 窗口重叠
+	}
+	for _, tt := range tests {
+		got, ok := s.NextWindow(tt.now)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("%s: 下个窗口为 %s，期望 %s", tt.now.Format("Mon 01-02 15:04"), got.Format("Mon 01-02 15:04"), tt.want.Format("Mon 01-02 15:04"))
+		}
+	}
+
+	// 仅 cron 窗口时，当前窗口结束后返回下一次触发时间
+	cron := mustSchedule(t, config.MaintenanceWindow{Cron: "0 3 * * Sat", Duration: 120})
+	if got, ok := cron.NextWindow(at(6, "05:00")); !ok || !got.Equal(at(13, "03:00")) {
+		t.Errorf("cron 下个窗口为 %s，期望 %s", got.Format("Mon 01-02 15:04"), at(13, "03:00").Format("Mon 01-02 15:04"))
+	}
+
+	// 窗口按配置时区计算，与传入时间的时区无关
+	shanghai, err := NewMaintenanceSchedule(config.MaintenanceConfig{
+		Windows:  []config.MaintenanceWindow{{Start: "02:00", End: "04:00"}},
+		Timezone: "Asia/Shanghai",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !shanghai.Allowed(at(3, "19:00")) { // 北京时间 03:00
+		t.Error("UTC 19:00 应在北京时间 02:00-04:00 窗口内")
+	}
+	if shanghai.Allowed(at(3, "03:00")) {
+		t.Error("UTC 03:00 不应在北京时间 02:00-04:00 窗口内")
+	}
+}
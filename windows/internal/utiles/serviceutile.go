@@ -1,182 +1,66 @@
 package utiles
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"os/exec"
+	"strings"
 	"time"
-
-	"golang.org/x/sys/windows/svc"
-	"golang.org/x/sys/windows/svc/mgr"
 )
 
-// WindowsServiceManager 提供对 Windows 服务的控制
-type WindowsServiceManager struct {
-	ServiceName string
-	mgr         *mgr.Mgr
-	service     *mgr.Service
-}
-
-// NewWindowsServiceManager 创建服务管理器并连接到指定服务
-func NewWindowsServiceManager(name string) (*WindowsServiceManager, error) {
-	wm := &WindowsServiceManager{ServiceName: name}
-	if err := wm.connect(); err != nil {
-		return nil, err
-	}
-	return wm, nil
-}
-
-// connect 连接到服务管理器和服务
-func (wm *WindowsServiceManager) connect() error {
-	var err error
-	wm.mgr, err = mgr.Connect()
-	if err != nil {
-		return fmt.Errorf("连接服务管理器失败: %v", err)
-	}
-	wm.service, err = wm.mgr.OpenService(wm.ServiceName)
-	if err != nil {
-		wm.mgr.Disconnect()
-		return fmt.Errorf("打开服务 %s 失败: %v", wm.ServiceName, err)
-	}
-	return nil
-}
-
-// Reconnect 用于在服务删除/重装后重新连接
-func (wm *WindowsServiceManager) Reconnect() error {
-	wm.Close()
-	return wm.connect()
-}
-
-// Close 释放资源
-func (wm *WindowsServiceManager) Close() error {
-	if wm.service != nil {
-		wm.service.Close()
-		wm.service = nil
-	}
-	if wm.mgr != nil {
-		err := wm.mgr.Disconnect()
-		wm.mgr = nil
-		return err
-	}
-	return nil
-}
-
-// checkReady 检查连接是否存在
-func (wm *WindowsServiceManager) checkReady() error {
-	if wm.mgr == nil || wm.service == nil {
-		return fmt.Errorf("服务未连接")
-	}
-	return nil
-}
+// serviceWaitTimeout 发送启动/停止命令后等待服务进入目标状态的时间
+const serviceWaitTimeout = 10 * time.Second
 
-// IsInstalled 判断服务是否存在
-func (wm *WindowsServiceManager) IsInstalled() (bool, error) {
-	if err := wm.checkReady(); err != nil {
-		return false, err
-	}
-	return true, nil
-}
-
-// Start 启动服务（仅在非运行状态下启动）
-func (wm *WindowsServiceManager) Start() error {
-	if err := wm.checkReady(); err != nil {
-		return err
-	}
-	state, err := wm.GetCurrentStatus()
-	if err != nil {
-		return err
-	}
-	if state == svc.Running {
-		return fmt.Errorf("服务已在运行，无需重复启动")
-	}
-	if err := wm.service.Start(); err != nil {
-		return fmt.Errorf("启动服务失败: %v", err)
-	}
-	return wm.waitForStatus(svc.Running, 10*time.Second)
-}
+// ServiceState 与平台无关的服务状态
+type ServiceState int
 
-// Stop 停止服务（仅在运行状态下停止）
-func (wm *WindowsServiceManager) Stop() error {
-	if err := wm.checkReady(); err != nil {
-		return err
-	}
-	state, err := wm.GetCurrentStatus()
-	if err != nil {
-		return err
-	}
-	if state == svc.Stopped {
-		return fmt.Errorf("服务已停止，无需重复停止")
-	}
-	status, err := wm.service.Control(svc.Stop)
-	if err != nil {
-		return fmt.Errorf("发送停止命令失败: %v", err)
-	}
-	// 等待停止
-	for i := 0; i < 10; i++ {
-		if status.State == svc.Stopped {
-			return nil
-		}
-		time.Sleep(time.Second)
-		status, err = wm.service.Query()
-		if err != nil {
-			return fmt.Errorf("查询服务状态失败: %v", err)
-		}
-	}
-	return fmt.Errorf("服务未在超时内停止")
-}
-
-// Restart 重启服务（避免重复操作）
-func (wm *WindowsServiceManager) Restart() error {
-	if err := wm.checkReady(); err != nil {
-		return err
-	}
-	state, err := wm.GetCurrentStatus()
-	if err != nil {
-		return err
-	}
-	if state == svc.Running {
-		if err := wm.Stop(); err != nil {
-			return fmt.Errorf("重启失败（停止失败）: %v", err)
-		}
-	}
-	// 即便之前是 Stopped 也照常启动
-	if err := wm.Start(); err != nil {
-		return fmt.Errorf("重启失败（启动失败）: %v", err)
-	}
-	return nil
-}
+const (
+	StateUnknown ServiceState = iota
+	StateStopped
+	StateStartPending
+	StateStopPending
+	StateRunning
+)
 
-// Status 返回服务状态字符串
-func (wm *WindowsServiceManager) Status() (string, error) {
-	state, err := wm.GetCurrentStatus()
-	if err != nil {
-		return "", err
-	}
-	switch state {
-	case svc.Stopped:
-		return "已停止", nil
-	case svc.Running:
-		return "正在运行", nil
+// String 返回服务状态的中文描述
+func (s ServiceState) String() string {
+	switch s {
+	case StateStopped:
+		return "已停止"
+	case StateStartPending:
+		return "正在启动"
+	case StateStopPending:
+		return "正在停止"
+	case StateRunning:
+		return "正在运行"
 	default:
-		return fmt.Sprintf("未知状态码: %d", state), nil
+		return "未知状态"
 	}
 }
 
-// GetCurrentStatus 返回当前 svc.State
-func (wm *WindowsServiceManager) GetCurrentStatus() (svc.State, error) {
-	if err := wm.checkReady(); err != nil {
-		return svc.Stopped, err
-	}
-	status, err := wm.service.Query()
-	if err != nil {
-		return svc.Stopped, fmt.Errorf("查询服务状态失败: %v", err)
-	}
-	return status.State, nil
+// ServiceController 控制 ZeroTier 服务的统一接口，由各平台后端实现
+type ServiceController interface {
+	// Name 返回被控制的服务名称
+	Name() string
+	// Status 返回服务当前状态
+	Status() (ServiceState, error)
+	// Start 启动服务并等待其进入运行状态
+	Start(ctx context.Context) error
+	// Stop 停止服务并等待其进入停止状态
+	Stop(ctx context.Context) error
+	// Restart 重启服务并等待其进入运行状态
+	Restart(ctx context.Context) error
+	// RestartTimeout 返回 Restart 最长的等待时间，即停止与启动的等待时间之和
+	RestartTimeout() time.Duration
+	// WaitForStatus 等待服务达到指定状态，ctx 取消时提前返回
+	WaitForStatus(ctx context.Context, target ServiceState, timeout time.Duration) error
+	// Close 释放资源
+	Close() error
 }
 
-// waitForStatus 等待服务达到指定状态
-func (wm *WindowsServiceManager) waitForStatus(target svc.State, timeout time.Duration) error {
-	if err := wm.checkReady(); err != nil {
-		return err
-	}
+// waitForState 轮询控制器状态，直到达到目标状态、超时或 ctx 取消
+func waitForState(ctx context.Context, c ServiceController, target ServiceState, timeout time.Duration) error {
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
@@ -185,15 +69,36 @@ func (wm *WindowsServiceManager) waitForStatus(target svc.State, timeout time.Du
 	for {
 		select {
 		case <-ticker.C:
-			status, err := wm.service.Query()
+			state, err := c.Status()
 			if err != nil {
-				return fmt.Errorf("查询服务状态失败: %v", err)
+				return err
 			}
-			if status.State == target {
+			if state == target {
 				return nil
 			}
 		case <-timeoutCh:
 			return fmt.Errorf("等待服务状态 %v 超时", target)
+		case <-ctx.Done():
+			return fmt.Errorf("等待服务状态 %v 被取消: %w", target, ctx.Err())
+		}
+	}
+}
+
+// runCommand 执行外部命令并返回去除首尾空白的标准输出，ctx 取消时终止命令
+func runCommand(ctx context.Context, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		if msg != "" {
+			return "", fmt.Errorf("执行 %s %s 失败: %v (%s)", name, strings.Join(args, " "), err, msg)
 		}
+		return "", fmt.Errorf("执行 %s %s 失败: %v", name, strings.Join(args, " "), err)
 	}
+	return strings.TrimSpace(stdout.String()), nil
 }
@@ -0,0 +1,74 @@
+package utiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// AgentState 扩展服务运行状态，保存到文件供 status 命令查看
+type AgentState struct {
+	LastCheck time.Time      `json:"lastCheck"`
+	Pending   *PendingChange `json:"pending,omitempty"`
+	// Restarts 近期由扩展服务发起的重启时间，用于限制重启频率
+	Restarts []time.Time   `json:"restarts,omitempty"`
+	Circuit  *CircuitState `json:"circuit,omitempty"`
+}
+
+// CircuitState 重启次数超限后打开的熔断状态
+type CircuitState struct {
+	OpenedAt time.Time `json:"openedAt"`
+	Until    time.Time `json:"until,omitempty"` // 自动恢复时间，为空时只能通过 reset 命令恢复
+	Reason   string    `json:"reason"`
+}
+
+// PendingChange 等待稳定的候选 IP 变更
+type PendingChange struct {
+	IPs       string    `json:"ips"`
+	FirstSeen time.Time `json:"firstSeen"`
+	Count     int       `json:"count"`
+}
+
+// LoadState 读取状态文件，文件不存在时返回空状态
+func LoadState(path string) (*AgentState, error) {
+	state := &AgentState{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("读取状态文件失败: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return &AgentState{}, fmt.Errorf("解析状态文件失败: %w", err)
+	}
+	return state, nil
+}
+
+// SaveState 原子写入状态文件
+func SaveState(path string, state *AgentState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化状态失败: %w", err)
+	}
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("写入状态文件失败: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("替换状态文件失败: %w", err)
+	}
+	return nil
+}
+
+// UpdateState 重新读取状态文件，修改后立即写回。
+// 状态文件也会被 reset 等命令修改，只修改需要变更的字段，避免覆盖其他进程写入的内容
+func UpdateState(path string, update func(state *AgentState)) error {
+	state, err := LoadState(path)
+	if err != nil {
+		return err
+	}
+	update(state)
+	return SaveState(path, state)
+}
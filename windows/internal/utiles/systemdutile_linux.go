@@ -0,0 +1,120 @@
+//go:build linux
+
+package utiles
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var _ ServiceController = (*SystemdServiceManager)(nil)
+
+// SystemdServiceManager 通过 systemctl 控制 systemd 服务
+type SystemdServiceManager struct {
+	ServiceName string
+}
+
+// NewSystemdServiceManager 创建 systemd 服务管理器并检查服务单元是否存在
+func NewSystemdServiceManager(name string) (*SystemdServiceManager, error) {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return nil, fmt.Errorf("未找到 systemctl: %v", err)
+	}
+	if !strings.Contains(name, ".") {
+		name += ".service"
+	}
+	sm := &SystemdServiceManager{ServiceName: name}
+	loadState, err := sm.show("LoadState")
+	if err != nil {
+		return nil, err
+	}
+	if loadState != "loaded" {
+		return nil, fmt.Errorf("服务 %s 不存在或未加载: %s", name, loadState)
+	}
+	return sm, nil
+}
+
+// Name 返回服务单元名称
+func (sm *SystemdServiceManager) Name() string {
+	return sm.ServiceName
+}
+
+// show 查询服务单元的指定属性
+func (sm *SystemdServiceManager) show(property string) (string, error) {
+	return runCommand(context.Background(), "systemctl", "show", "-p", property, "--value", sm.ServiceName)
+}
+
+// Status 返回服务当前状态
+func (sm *SystemdServiceManager) Status() (ServiceState, error) {
+	activeState, err := sm.show("ActiveState")
+	if err != nil {
+		return StateUnknown, fmt.Errorf("查询服务状态失败: %v", err)
+	}
+	switch activeState {
+	case "active":
+		return StateRunning, nil
+	case "activating", "reloading":
+		return StateStartPending, nil
+	case "deactivating":
+		return StateStopPending, nil
+	case "inactive", "failed":
+		return StateStopped, nil
+	default:
+		return StateUnknown, nil
+	}
+}
+
+// Start 启动服务（仅在非运行状态下启动）
+func (sm *SystemdServiceManager) Start(ctx context.Context) error {
+	state, err := sm.Status()
+	if err != nil {
+		return err
+	}
+	if state == StateRunning {
+		return fmt.Errorf("服务已在运行，无需重复启动")
+	}
+	if _, err := runCommand(ctx, "systemctl", "start", sm.ServiceName); err != nil {
+		return fmt.Errorf("启动服务失败: %v", err)
+	}
+	return sm.WaitForStatus(ctx, StateRunning, serviceWaitTimeout)
+}
+
+// Stop 停止服务（仅在运行状态下停止）
+func (sm *SystemdServiceManager) Stop(ctx context.Context) error {
+	state, err := sm.Status()
+	if err != nil {
+		return err
+	}
+	if state == StateStopped {
+		return fmt.Errorf("服务已停止，无需重复停止")
+	}
+	if _, err := runCommand(ctx, "systemctl", "stop", sm.ServiceName); err != nil {
+		return fmt.Errorf("发送停止命令失败: %v", err)
+	}
+	return sm.WaitForStatus(ctx, StateStopped, serviceWaitTimeout)
+}
+
+// Restart 重启服务，未运行时直接启动
+func (sm *SystemdServiceManager) Restart(ctx context.Context) error {
+	if _, err := runCommand(ctx, "systemctl", "restart", sm.ServiceName); err != nil {
+		return fmt.Errorf("重启服务失败: %v", err)
+	}
+	return sm.WaitForStatus(ctx, StateRunning, serviceWaitTimeout)
+}
+
+// RestartTimeout systemctl restart 依次停止和启动服务
+func (sm *SystemdServiceManager) RestartTimeout() time.Duration {
+	return 2 * serviceWaitTimeout
+}
+
+// WaitForStatus 等待服务达到指定状态
+func (sm *SystemdServiceManager) WaitForStatus(ctx context.Context, target ServiceState, timeout time.Duration) error {
+	return waitForState(ctx, sm, target, timeout)
+}
+
+// Close systemd 后端无需释放资源
+func (sm *SystemdServiceManager) Close() error {
+	return nil
+}
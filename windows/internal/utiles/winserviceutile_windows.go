@@ -0,0 +1,200 @@
+//go:build windows
+
+package utiles
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+var _ ServiceController = (*WindowsServiceManager)(nil)
+
+// WindowsServiceManager 提供对 Windows 服务的控制
+type WindowsServiceManager struct {
+	ServiceName string
+	mgr         *mgr.Mgr
+	service     *mgr.Service
+}
+
+// NewWindowsServiceManager 创建服务管理器并连接到指定服务
+func NewWindowsServiceManager(name string) (*WindowsServiceManager, error) {
+	wm := &WindowsServiceManager{ServiceName: name}
+	if err := wm.connect(); err != nil {
+		return nil, err
+	}
+	return wm, nil
+}
+
+// Name 返回服务名称
+func (wm *WindowsServiceManager) Name() string {
+	return wm.ServiceName
+}
+
+// connect 连接到服务管理器和服务
+func (wm *WindowsServiceManager) connect() error {
+	var err error
+	wm.mgr, err = mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("连接服务管理器失败: %v", err)
+	}
+	wm.service, err = wm.mgr.OpenService(wm.ServiceName)
+	if err != nil {
+		wm.mgr.Disconnect()
+		return fmt.Errorf("打开服务 %s 失败: %v", wm.ServiceName, err)
+	}
+	return nil
+}
+
+// Reconnect 用于在服务删除/重装后重新连接
+func (wm *WindowsServiceManager) Reconnect() error {
+	wm.Close()
+	return wm.connect()
+}
+
+// Close 释放资源
+func (wm *WindowsServiceManager) Close() error {
+	if wm.service != nil {
+		wm.service.Close()
+		wm.service = nil
+	}
+	if wm.mgr != nil {
+		err := wm.mgr.Disconnect()
+		wm.mgr = nil
+		return err
+	}
+	return nil
+}
+
+// checkReady 检查连接是否存在
+func (wm *WindowsServiceManager) checkReady() error {
+	if wm.mgr == nil || wm.service == nil {
+		return fmt.Errorf("服务未连接")
+	}
+	return nil
+}
+
+// IsInstalled 判断服务是否存在
+func (wm *WindowsServiceManager) IsInstalled() (bool, error) {
+	if err := wm.checkReady(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Start 启动服务（仅在非运行状态下启动）
+func (wm *WindowsServiceManager) Start(ctx context.Context) error {
+	if err := wm.checkReady(); err != nil {
+		return err
+	}
+	state, err := wm.Status()
+	if err != nil {
+		return err
+	}
+	if state == StateRunning {
+		return fmt.Errorf("服务已在运行，无需重复启动")
+	}
+	if err := wm.service.Start(); err != nil {
+		return fmt.Errorf("启动服务失败: %v", err)
+	}
+	return wm.WaitForStatus(ctx, StateRunning, serviceWaitTimeout)
+}
+
+// Stop 停止服务（仅在运行状态下停止）
+func (wm *WindowsServiceManager) Stop(ctx context.Context) error {
+	if err := wm.checkReady(); err != nil {
+		return err
+	}
+	state, err := wm.Status()
+	if err != nil {
+		return err
+	}
+	if state == StateStopped {
+		return fmt.Errorf("服务已停止，无需重复停止")
+	}
+	status, err := wm.service.Control(svc.Stop)
+	if err != nil {
+		return fmt.Errorf("发送停止命令失败: %v", err)
+	}
+	// 等待停止
+	for i := 0; i < int(serviceWaitTimeout/time.Second); i++ {
+		if status.State == svc.Stopped {
+			return nil
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return fmt.Errorf("等待服务停止被取消: %w", ctx.Err())
+		}
+		status, err = wm.service.Query()
+		if err != nil {
+			return fmt.Errorf("查询服务状态失败: %v", err)
+		}
+	}
+	return fmt.Errorf("服务未在超时内停止")
+}
+
+// Restart 重启服务（避免重复操作）
+func (wm *WindowsServiceManager) Restart(ctx context.Context) error {
+	if err := wm.checkReady(); err != nil {
+		return err
+	}
+	state, err := wm.Status()
+	if err != nil {
+		return err
+	}
+	if state == StateRunning {
+		if err := wm.Stop(ctx); err != nil {
+			return fmt.Errorf("重启失败（停止失败）: %v", err)
+		}
+	}
+	// 即便之前是 Stopped 也照常启动
+	if err := wm.Start(ctx); err != nil {
+		return fmt.Errorf("重启失败（启动失败）: %v", err)
+	}
+	return nil
+}
+
+// Status 返回服务当前状态
+func (wm *WindowsServiceManager) Status() (ServiceState, error) {
+	if err := wm.checkReady(); err != nil {
+		return StateUnknown, err
+	}
+	status, err := wm.service.Query()
+	if err != nil {
+		return StateUnknown, fmt.Errorf("查询服务状态失败: %v", err)
+	}
+	return fromSvcState(status.State), nil
+}
+
+// fromSvcState 将 svc.State 转换为平台无关的 ServiceState
+func fromSvcState(state svc.State) ServiceState {
+	switch state {
+	case svc.Stopped:
+		return StateStopped
+	case svc.StartPending, svc.ContinuePending:
+		return StateStartPending
+	case svc.StopPending, svc.PausePending:
+		return StateStopPending
+	case svc.Running:
+		return StateRunning
+	default:
+		return StateUnknown
+	}
+}
+
+// RestartTimeout 停止和启动各等待 serviceWaitTimeout
+func (wm *WindowsServiceManager) RestartTimeout() time.Duration {
+	return 2 * serviceWaitTimeout
+}
+
+// WaitForStatus 等待服务达到指定状态
+func (wm *WindowsServiceManager) WaitForStatus(ctx context.Context, target ServiceState, timeout time.Duration) error {
+	if err := wm.checkReady(); err != nil {
+		return err
+	}
+	return waitForState(ctx, wm, target, timeout)
+}
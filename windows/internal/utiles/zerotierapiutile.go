@@ -0,0 +1,157 @@
+package utiles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+const defaultZeroTierAPIURL = "http://127.0.0.1:9993"
+
+// ZeroTierAPI 访问 ZeroTier 本地 JSON API
+type ZeroTierAPI struct {
+	BaseURL   string
+	TokenPath string
+	client    *http.Client
+}
+
+// NodeStatus /status 接口返回的节点状态
+type NodeStatus struct {
+	Address string `json:"address"`
+	Online  bool   `json:"online"`
+	Version string `json:"version"`
+}
+
+// PeerPath 节点的一条物理路径
+type PeerPath struct {
+	Address   string `json:"address"`
+	Active    bool   `json:"active"`
+	Expired   bool   `json:"expired"`
+	Preferred bool   `json:"preferred"`
+}
+
+// Peer /peer 接口返回的对端信息
+type Peer struct {
+	Address string     `json:"address"`
+	Role    string     `json:"role"`
+	Paths   []PeerPath `json:"paths"`
+}
+
+// NewZeroTierAPI 根据配置创建本地 API 客户端
+func NewZeroTierAPI(cfg config.ZeroTierAPIConfig) *ZeroTierAPI {
+	baseURL := cfg.URL
+	if baseURL == "" {
+		baseURL = defaultZeroTierAPIURL
+	}
+	return &ZeroTierAPI{
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		TokenPath: cfg.AuthTokenPath,
+		client:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// get 请求本地 API 并解析 JSON 响应，每次读取 authtoken.secret 以兼容服务重启后重新生成的情况
+func (za *ZeroTierAPI) get(ctx context.Context, path string, v interface{}) error {
+	token, err := os.ReadFile(za.TokenPath)
+	if err != nil {
+		return fmt.Errorf("读取authtoken失败: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, za.BaseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("X-ZT1-Auth", strings.TrimSpace(string(token)))
+	resp, err := za.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求本地API失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("本地API返回状态码: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("解析本地API响应失败: %w", err)
+	}
+	return nil
+}
+
+// Status 查询节点状态
+func (za *ZeroTierAPI) Status(ctx context.Context) (*NodeStatus, error) {
+	var status NodeStatus
+	if err := za.get(ctx, "/status", &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Peers 查询所有对端
+func (za *ZeroTierAPI) Peers(ctx context.Context) ([]Peer, error) {
+	var peers []Peer
+	if err := za.get(ctx, "/peer", &peers); err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
+
+// ErrRootUnreachable 本地API正常，但节点未上线或无法连接任何根服务器
+var ErrRootUnreachable = errors.New("节点与根服务器失联")
+
+// CheckHealth 检查节点是否在线且至少一个 PLANET 节点存在活动路径
+func (za *ZeroTierAPI) CheckHealth(ctx context.Context) error {
+	status, err := za.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if !status.Online {
+		return fmt.Errorf("%w: 节点 %s 未上线", ErrRootUnreachable, status.Address)
+	}
+	peers, err := za.Peers(ctx)
+	if err != nil {
+		return err
+	}
+	planets := 0
+	for _, peer := range peers {
+		if peer.Role != "PLANET" {
+			continue
+		}
+		planets++
+		for _, path := range peer.Paths {
+			if path.Active || (path.Preferred && !path.Expired) {
+				return nil
+			}
+		}
+	}
+	if planets == 0 {
+		return fmt.Errorf("%w: 未发现PLANET节点", ErrRootUnreachable)
+	}
+	return fmt.Errorf("%w: %d个PLANET节点均无活动路径", ErrRootUnreachable, planets)
+}
+
+// WaitForHealthy 轮询节点状态直到健康、超时或 ctx 取消，超时返回包装了最后一次检查错误的错误，
+// 可用 errors.Is 判断是否为 ErrRootUnreachable
+func (za *ZeroTierAPI) WaitForHealthy(ctx context.Context, timeout time.Duration) error {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	timeoutCh := time.After(timeout)
+	lastErr := za.CheckHealth(ctx)
+	for lastErr != nil {
+		select {
+		case <-ticker.C:
+			lastErr = za.CheckHealth(ctx)
+		case <-timeoutCh:
+			return fmt.Errorf("等待节点上线超时: %w", lastErr)
+		case <-ctx.Done():
+			return fmt.Errorf("等待节点上线被取消: %w", ctx.Err())
+		}
+	}
+	return nil
+}
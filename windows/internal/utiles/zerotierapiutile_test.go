@@ -0,0 +1,172 @@
+package utiles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	config "github.com/onlypeng/zerotier-extend/windows/internal/config"
+)
+
+const testAuthToken = "zt-token"
+
+// zeroTierAPIServer 模拟本地 API，校验 X-ZT1-Auth 后返回指定的状态和对端列表
+func zeroTierAPIServer(t *testing.T, status NodeStatus, peers []Peer) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-ZT1-Auth") != testAuthToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/status":
+			json.NewEncoder(w).Encode(status)
+		case "/peer":
+			json.NewEncoder(w).Encode(peers)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestZeroTierAPI 创建使用指定 token 文件内容的 API 客户端，token 为空时不创建文件
+func newTestZeroTierAPI(t *testing.T, url, token string) *ZeroTierAPI {
+	t.Helper()
+	tokenPath := filepath.Join(t.TempDir(), "authtoken.secret")
+	if token != "" {
+		if err := os.WriteFile(tokenPath, []byte(token+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewZeroTierAPI(config.ZeroTierAPIConfig{URL: url + "/", AuthTokenPath: tokenPath})
+}
+
+func TestCheckHealth(t *testing.T) {
+	online := NodeStatus{Address: "1122334455", Online: true}
+	leaf := Peer{Address: "aaaaaaaaaa", Role: "LEAF", Paths: []PeerPath{{Active: true}}}
+	tests := []struct {
+		name        string
+		status      NodeStatus
+		peers       []Peer
+		token       string // 为空时使用正确的 token
+		noToken     bool   // 不创建 token 文件
+		ok          bool
+		unreachable bool // 期望返回 ErrRootUnreachable
+	}{
+		{
+			name:   "PLANET节点有活动路径",
+			status: online,
+			peers:  []Peer{leaf, {Role: "PLANET", Paths: []PeerPath{{Active: true}}}},
+			ok:     true,
+		},
+		{
+			name:   "首选且未过期的路径",
+			status: online,
+			peers:  []Peer{{Role: "PLANET", Paths: []PeerPath{{Preferred: true}}}},
+			ok:     true,
+		},
+		{
+			name:   "任一PLANET节点可达即可",
+			status: online,
+			peers:  []Peer{{Role: "PLANET"}, {Role: "PLANET", Paths: []PeerPath{{Active: true}}}},
+			ok:     true,
+		},
+		{
+			name:        "节点未上线",
+			status:      NodeStatus{Address: "1122334455"},
+			peers:       []Peer{{Role: "PLANET", Paths: []PeerPath{{Active: true}}}},
+			unreachable: true,
+		},
+		{
+			name:        "没有PLANET节点",
+			status:      online,
+			peers:       []Peer{leaf, {Role: "MOON", Paths: []PeerPath{{Active: true}}}},
+			unreachable: true,
+		},
+		{
+			name:        "没有对端",
+			status:      online,
+			unreachable: true,
+		},
+		{
+			name:   "PLANET节点均无活动路径",
+			status: online,
+			peers: []Peer{
+				{Role: "PLANET"},
+				{Role: "PLANET", Paths: []PeerPath{{Preferred: true, Expired: true}, {}}},
+				leaf,
+			},
+			unreachable: true,
+		},
+		{
+			name:   "token错误",
+			status: online,
+			peers:  []Peer{{Role: "PLANET", Paths: []PeerPath{{Active: true}}}},
+			token:  "wrong",
+		},
+		{
+			name:    "token文件不存在",
+			status:  online,
+			peers:   []Peer{{Role: "PLANET", Paths: []PeerPath{{Active: true}}}},
+			noToken: true,
+		},
+	}
+	for _, tt := range tests {
+		srv := zeroTierAPIServer(t, tt.status, tt.peers)
+		token := testAuthToken
+		if tt.token != "" {
+			token = tt.token
+		}
+		if tt.noToken {
+			token = ""
+		}
+		err := newTestZeroTierAPI(t, srv.URL, token).CheckHealth(context.Background())
+		if (err == nil) != tt.ok || errors.Is(err, ErrRootUnreachable) != tt.unreachable {
+			t.Errorf("%s: 返回 %v", tt.name, err)
+		}
+	}
+}
+
+func TestCheckHealthAPIUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+	// 本地 API 无法连接时不视为与根服务器失联
+	err := newTestZeroTierAPI(t, url, testAuthToken).CheckHealth(context.Background())
+	if err == nil || errors.Is(err, ErrRootUnreachable) {
+		t.Errorf("返回 %v", err)
+	}
+}
+
+func TestWaitForHealthy(t *testing.T) {
+	healthy := zeroTierAPIServer(t, NodeStatus{Online: true}, []Peer{{Role: "PLANET", Paths: []PeerPath{{Active: true}}}})
+	if err := newTestZeroTierAPI(t, healthy.URL, testAuthToken).WaitForHealthy(context.Background(), time.Second); err != nil {
+		t.Errorf("节点健康时返回 %v", err)
+	}
+
+	offline := zeroTierAPIServer(t, NodeStatus{Online: true}, []Peer{{Role: "PLANET"}})
+	api := newTestZeroTierAPI(t, offline.URL, testAuthToken)
+	start := time.Now()
+	err := api.WaitForHealthy(context.Background(), 300*time.Millisecond)
+	if !errors.Is(err, ErrRootUnreachable) || !strings.Contains(err.Error(), "超时") {
+		t.Errorf("超时返回 %v，期望包含 ErrRootUnreachable", err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("等待了 %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := api.WaitForHealthy(ctx, time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("取消时返回 %v", err)
+	}
+}
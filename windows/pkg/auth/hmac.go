@@ -0,0 +1,301 @@
+// Package auth 实现请求服务器时使用的 HMAC 签名认证，包括客户端签名和服务端校验
+//
+// 请求头格式：
+//
+//	Authorization: ZT-HMAC-SHA256 keyId=<id>, ts=<unix秒>, nonce=<随机十六进制>, sig=<base64>
+//
+// 签名为 HMAC-SHA256(secret, "ZT-HMAC-SHA256\n" + 方法 + "\n" + 路径(含查询串) + "\n" + ts + "\n" + nonce)。
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Scheme Authorization 头使用的认证方案名
+	Scheme = "ZT-HMAC-SHA256"
+	// DefaultMaxSkew 服务端允许的默认时间偏差
+	DefaultMaxSkew = 5 * time.Minute
+)
+
+// stringToSign 构造待签名字符串
+func stringToSign(method, path string, ts int64, nonce string) string {
+	return Scheme + "\n" + method + "\n" + path + "\n" + strconv.FormatInt(ts, 10) + "\n" + nonce
+}
+
+// requestPath 返回参与签名的路径和查询串
+func requestPath(r *http.Request) string {
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+	return path
+}
+
+// sign 计算签名
+func sign(secret []byte, method, path string, ts int64, nonce string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(stringToSign(method, path, ts, nonce)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Signer 客户端签名器，根据服务器响应的 Date 头估算本地时钟偏差并在签名时修正
+type Signer struct {
+	KeyID  string
+	Secret []byte
+	// SkewWarn 本地时钟与服务器相差超过该值时记录告警
+	SkewWarn time.Duration
+	// MaxOffset 签名时最多修正的时钟偏差，默认 DefaultMaxSkew
+	MaxOffset time.Duration
+
+	mu     sync.Mutex
+	offset time.Duration // 服务器时间 - 本地时间
+	warned bool
+}
+
+// Sign 为请求添加 Authorization 头
+func (s *Signer) Sign(r *http.Request) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("生成随机数失败: %w", err)
+	}
+	n := hex.EncodeToString(nonce)
+	ts := time.Now().Add(s.Offset()).Unix()
+	sig := sign(s.Secret, r.Method, requestPath(r), ts, n)
+	r.Header.Set("Authorization", fmt.Sprintf("%s keyId=%s, ts=%d, nonce=%s, sig=%s", Scheme, s.KeyID, ts, n, sig))
+	return nil
+}
+
+// Offset 返回估算的时钟偏差（服务器时间 - 本地时间）
+func (s *Signer) Offset() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset
+}
+
+// ObserveDate 根据响应的 Date 头估算时钟偏差，Date 精度为秒，2 秒以内的偏差忽略。
+// 只有 2xx 响应（服务器已接受签名）的偏差用于修正签名时间，且不超过 MaxOffset，
+// 避免伪造的错误响应任意改变签名时间；其他响应只用于告警
+func (s *Signer) ObserveDate(resp *http.Response, sent time.Time) {
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// 以请求发出与收到响应的中点作为服务器生成 Date 的本地时间
+	local := sent.Add(time.Since(sent) / 2)
+	offset := date.Sub(local)
+	if offset > -2*time.Second && offset < 2*time.Second {
+		offset = 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resp.StatusCode/100 == 2 {
+		s.offset = clamp(offset, s.maxOffset())
+	}
+	abs := offset
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case s.SkewWarn > 0 && abs > s.SkewWarn && !s.warned:
+		log.Printf("告警: 本地时钟与服务器相差%v，请同步系统时间，签名将按服务器时间修正", offset.Round(time.Second))
+		s.warned = true
+	case abs <= s.SkewWarn && s.warned:
+		log.Printf("本地时钟与服务器偏差已恢复正常(%v)", offset.Round(time.Second))
+		s.warned = false
+	}
+}
+
+func (s *Signer) maxOffset() time.Duration {
+	if s.MaxOffset > 0 {
+		return s.MaxOffset
+	}
+	return DefaultMaxSkew
+}
+
+// clamp 将 d 限制在 [-limit, limit] 内
+func clamp(d, limit time.Duration) time.Duration {
+	switch {
+	case d > limit:
+		return limit
+	case d < -limit:
+		return -limit
+	}
+	return d
+}
+
+// Transport 为每个请求签名的 RoundTripper
+type Transport struct {
+	Base   http.RoundTripper
+	Signer *Signer
+}
+
+// RoundTrip 复制请求并签名后发送，同时根据响应检测时钟偏差
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	signed := req.Clone(req.Context())
+	if err := t.Signer.Sign(signed); err != nil {
+		return nil, err
+	}
+	sent := time.Now()
+	resp, err := t.Base.RoundTrip(signed)
+	if err != nil {
+		return nil, err
+	}
+	t.Signer.ObserveDate(resp, sent)
+	return resp, nil
+}
+
+// CloseIdleConnections 关闭空闲连接
+func (t *Transport) CloseIdleConnections() {
+	if c, ok := t.Base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// 服务端校验错误
+var (
+	ErrMissing   = errors.New("缺少签名")
+	ErrMalformed = errors.New("签名格式错误")
+	ErrUnknownID = errors.New("未知的keyId")
+	ErrExpired   = errors.New("签名时间超出允许范围")
+	ErrReplay    = errors.New("重复的请求")
+	ErrSignature = errors.New("签名不匹配")
+)
+
+// Verifier 服务端签名校验器，在允许的时间偏差内记录已使用的 nonce 以防重放
+type Verifier struct {
+	// Lookup 根据 keyId 返回密钥
+	Lookup func(keyID string) ([]byte, bool)
+	// MaxSkew 允许的客户端与服务端时间偏差，默认 DefaultMaxSkew
+	MaxSkew time.Duration
+	// Now 返回当前时间，默认 time.Now
+	Now func() time.Time
+
+	// nonce 按时间分为当前和上一个两段记录，每段时长为两倍时间偏差，
+	// 轮换时整段丢弃，无需逐条检查过期
+	mu          sync.Mutex
+	nonces      map[string]struct{}
+	prevNonces  map[string]struct{}
+	bucketStart time.Time
+}
+
+// Verify 校验请求签名，成功时返回 keyId
+func (v *Verifier) Verify(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", ErrMissing
+	}
+	params, err := parseAuthorization(header)
+	if err != nil {
+		return "", err
+	}
+	keyID, nonce, sig := params["keyId"], params["nonce"], params["sig"]
+	ts, err := strconv.ParseInt(params["ts"], 10, 64)
+	if err != nil || nonce == "" || sig == "" {
+		return "", ErrMalformed
+	}
+
+	secret, ok := v.Lookup(keyID)
+	if !ok {
+		return "", ErrUnknownID
+	}
+	expected := sign(secret, r.Method, requestPath(r), ts, nonce)
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return "", ErrSignature
+	}
+
+	now, maxSkew := v.now(), v.maxSkew()
+	diff := now.Sub(time.Unix(ts, 0))
+	if diff > maxSkew || diff < -maxSkew {
+		return "", fmt.Errorf("%w: 请求时间与服务器相差%v", ErrExpired, diff.Round(time.Second))
+	}
+	if !v.useNonce(keyID+"/"+nonce, now, maxSkew) {
+		return "", ErrReplay
+	}
+	return keyID, nil
+}
+
+// Middleware 返回校验签名的 HTTP 中间件，失败时返回 401 并附带 Date 头供客户端校准时钟
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := v.Verify(r); err != nil {
+			w.Header().Set("WWW-Authenticate", Scheme)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+func (v *Verifier) maxSkew() time.Duration {
+	if v.MaxSkew > 0 {
+		return v.MaxSkew
+	}
+	return DefaultMaxSkew
+}
+
+// useNonce 记录 nonce，已使用过时返回 false。签名时间在前后 maxSkew 内有效，
+// 因此 nonce 至少保留两倍 maxSkew，超过四倍 maxSkew 的记录随分段轮换丢弃
+func (v *Verifier) useNonce(key string, now time.Time, maxSkew time.Duration) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	period := 2 * maxSkew
+	switch elapsed := now.Sub(v.bucketStart); {
+	case v.nonces == nil || elapsed >= 2*period:
+		v.prevNonces = nil
+		v.nonces = make(map[string]struct{})
+		v.bucketStart = now
+	case elapsed >= period:
+		v.prevNonces = v.nonces
+		v.nonces = make(map[string]struct{})
+		v.bucketStart = v.bucketStart.Add(period)
+	}
+	if _, ok := v.nonces[key]; ok {
+		return false
+	}
+	if _, ok := v.prevNonces[key]; ok {
+		return false
+	}
+	v.nonces[key] = struct{}{}
+	return true
+}
+
+// parseAuthorization 解析 Authorization 头中的参数
+func parseAuthorization(header string) (map[string]string, error) {
+	scheme, rest, ok := strings.Cut(header, " ")
+	if !ok || scheme != Scheme {
+		return nil, ErrMalformed
+	}
+	params := make(map[string]string)
+	for _, part := range strings.Split(rest, ",") {
+		k, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, ErrMalformed
+		}
+		params[k] = val
+	}
+	return params, nil
+}
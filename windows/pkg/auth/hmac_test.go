@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("test-secret")
+
+func testVerifier(now func() time.Time) *Verifier {
+	return &Verifier{
+		Lookup: func(keyID string) ([]byte, bool) {
+			if keyID == "node1" {
+				return testSecret, true
+			}
+			return nil, false
+		},
+		Now: now,
+	}
+}
+
+// signedRequest 返回由 node1 签名的请求
+func signedRequest(t *testing.T, target string) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	s := &Signer{KeyID: "node1", Secret: testSecret}
+	if err := s.Sign(r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	v := testVerifier(nil)
+	srv := httptest.NewServer(v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Transport{
+		Base:   http.DefaultTransport,
+		Signer: &Signer{KeyID: "node1", Secret: testSecret},
+	}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL + "/planet?v=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("第%d次请求状态码为 %d", i+1, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/planet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") != Scheme {
+		t.Errorf("未签名请求状态码为 %d", resp.StatusCode)
+	}
+}
+
+func TestVerifyRejectsSkew(t *testing.T) {
+	tests := []struct {
+		offset time.Duration
+		want   error
+	}{
+		{4 * time.Minute, nil},
+		{-4 * time.Minute, nil},
+		{6 * time.Minute, ErrExpired},
+		{-6 * time.Minute, ErrExpired},
+	}
+	for _, tt := range tests {
+		v := testVerifier(func() time.Time { return time.Now().Add(tt.offset) })
+		_, err := v.Verify(signedRequest(t, "/planet"))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("服务器时间偏差 %v: 错误为 %v，期望 %v", tt.offset, err, tt.want)
+		}
+	}
+
+	v := testVerifier(nil)
+	v.MaxSkew = time.Second
+	v.Now = func() time.Time { return time.Now().Add(3 * time.Second) }
+	if _, err := v.Verify(signedRequest(t, "/planet")); !errors.Is(err, ErrExpired) {
+		t.Errorf("自定义 MaxSkew 未生效: %v", err)
+	}
+}
+
+func TestVerifyRejectsReplay(t *testing.T) {
+	now := time.Now()
+	v := testVerifier(func() time.Time { return now })
+	r := signedRequest(t, "/ips")
+	if _, err := v.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(r); !errors.Is(err, ErrReplay) {
+		t.Errorf("重放请求错误为 %v，期望 %v", err, ErrReplay)
+	}
+	// nonce 记录过期清理后，请求时间本身也已超出允许范围，仍不能重放
+	now = now.Add(2*DefaultMaxSkew + time.Second)
+	if _, err := v.Verify(r); !errors.Is(err, ErrExpired) {
+		t.Errorf("过期的重放请求错误为 %v，期望 %v", err, ErrExpired)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	v := testVerifier(nil)
+	tests := []struct {
+		name   string
+		mutate func(r *http.Request)
+		want   error
+	}{
+		{"缺少签名", func(r *http.Request) { r.Header.Del("Authorization") }, ErrMissing},
+		{"修改路径", func(r *http.Request) { r.URL.Path = "/ips" }, ErrSignature},
+		{"修改查询串", func(r *http.Request) { r.URL.RawQuery = "v=2" }, ErrSignature},
+		{"修改方法", func(r *http.Request) { r.Method = http.MethodPost }, ErrSignature},
+		{"未知keyId", func(r *http.Request) {
+			r.Header.Set("Authorization", strings.Replace(r.Header.Get("Authorization"), "keyId=node1", "keyId=node2", 1))
+		}, ErrUnknownID},
+		{"其他方案", func(r *http.Request) { r.Header.Set("Authorization", "Bearer abc") }, ErrMalformed},
+		{"缺少时间", func(r *http.Request) {
+			r.Header.Set("Authorization", Scheme+" keyId=node1, nonce=00, sig=xx")
+		}, ErrMalformed},
+	}
+	for _, tt := range tests {
+		r := signedRequest(t, "/planet?v=1")
+		tt.mutate(r)
+		if _, err := v.Verify(r); !errors.Is(err, tt.want) {
+			t.Errorf("%s: 错误为 %v，期望 %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestNonceBuckets(t *testing.T) {
+	start := time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+	const skew = time.Minute
+	v := testVerifier(nil)
+	use := func(key string, after time.Duration) bool {
+		return v.useNonce(key, start.Add(after), skew)
+	}
+
+	if !use("a", 0) || use("a", 0) {
+		t.Fatal("首次使用应成功，重复使用应失败")
+	}
+	if !use("b", 90*time.Second) {
+		t.Fatal("nonce b 首次使用失败")
+	}
+	// 轮换到下一段后，上一段的 nonce 仍被拒绝
+	if !use("c", 2*skew) || use("a", 2*skew) || use("b", 3*skew+30*time.Second) {
+		t.Error("轮换后上一段的 nonce 未被拒绝")
+	}
+	if len(v.prevNonces) != 2 || len(v.nonces) != 1 {
+		t.Errorf("当前段 %d 条、上一段 %d 条记录", len(v.nonces), len(v.prevNonces))
+	}
+	// 每个 nonce 至少保留两倍 maxSkew
+	if use("c", 4*skew-time.Second) {
+		t.Error("nonce c 未满两倍 maxSkew 即被清理")
+	}
+	if !use("a", 4*skew) || len(v.prevNonces) != 1 || len(v.nonces) != 1 {
+		t.Errorf("轮换后未清理更早的记录: 当前段 %v，上一段 %v", v.nonces, v.prevNonces)
+	}
+	// 长时间没有请求后全部清理
+	if !use("c", 20*skew) || len(v.prevNonces) != 0 || len(v.nonces) != 1 {
+		t.Errorf("长时间无请求后未清理: 当前段 %v，上一段 %v", v.nonces, v.prevNonces)
+	}
+	// 时钟回拨时不清理
+	if use("c", 10*skew) {
+		t.Error("时钟回拨后 nonce 被清理")
+	}
+}
+
+func TestObserveDate(t *testing.T) {
+	sent := time.Now()
+	response := func(code int, offset time.Duration) *http.Response {
+		h := http.Header{}
+		h.Set("Date", sent.Add(offset).UTC().Format(http.TimeFormat))
+		return &http.Response{StatusCode: code, Header: h}
+	}
+	tests := []struct {
+		code   int
+		offset time.Duration
+		want   time.Duration
+	}{
+		{http.StatusOK, time.Second, 0},
+		{http.StatusOK, 90 * time.Second, 90 * time.Second},
+		{http.StatusNoContent, -2 * time.Minute, -2 * time.Minute},
+		{http.StatusOK, 3 * time.Hour, DefaultMaxSkew},
+		{http.StatusOK, -3 * time.Hour, -DefaultMaxSkew},
+		{http.StatusUnauthorized, 90 * time.Second, 0},
+		{http.StatusNotModified, 90 * time.Second, 0},
+		{http.StatusServiceUnavailable, time.Hour, 0},
+	}
+	for _, tt := range tests {
+		s := &Signer{KeyID: "node1", Secret: testSecret, SkewWarn: 30 * time.Second}
+		s.ObserveDate(response(tt.code, tt.offset), sent)
+		if got := s.Offset(); got < tt.want-2*time.Second || got > tt.want+2*time.Second {
+			t.Errorf("状态码 %d 偏差 %v: 修正 %v，期望约 %v", tt.code, tt.offset, got, tt.want)
+		}
+	}
+
+	// 非 2xx 响应不改变已有的修正，自定义 MaxOffset 生效
+	s := &Signer{KeyID: "node1", Secret: testSecret, MaxOffset: time.Minute}
+	s.ObserveDate(response(http.StatusOK, 10*time.Minute), sent)
+	s.ObserveDate(response(http.StatusUnauthorized, -10*time.Minute), sent)
+	if got := s.Offset(); got != time.Minute {
+		t.Errorf("修正为 %v，期望 %v", got, time.Minute)
+	}
+}
+
+// TestTransportAdoptsOffsetFromAcceptedResponses 服务器拒绝签名时不采用其 Date 头
+func TestTransportAdoptsOffsetFromAcceptedResponses(t *testing.T) {
+	reject := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		if reject {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	signer := &Signer{KeyID: "node1", Secret: testSecret}
+	client := &http.Client{Transport: &Transport{Base: http.DefaultTransport, Signer: signer}}
+
+	get := func() {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	get()
+	if got := signer.Offset(); got != 0 {
+		t.Errorf("401 响应后修正为 %v", got)
+	}
+	reject = false
+	get()
+	if got := signer.Offset(); got != DefaultMaxSkew {
+		t.Errorf("2xx 响应后修正为 %v，期望 %v", got, DefaultMaxSkew)
+	}
+}
@@ -0,0 +1,2 @@
+#!/bin/sh
+cd "$(dirname "$0")" && GOOS=linux go build -o update_planet ../cmd/zerotierextend